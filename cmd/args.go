@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// resourceArg is a TYPE/NAME positional argument
+type resourceArg struct {
+	Type string
+	Name string
+}
+
+var (
+	// resourceType is the positional TYPE[.group] argument
+	resourceType string
+	// resourceArgs are the positional TYPE/NAME arguments, fetched directly
+	resourceArgs []resourceArg
+	// walkOwners diagnoses TYPE/NAME arguments from the claim or composite that owns them
+	walkOwners bool
+)
+
+// applyArgs applies the positional arguments, accepted in the same forms as
+// kubectl get: TYPE[.group] [NAME], or one or more TYPE[.group]/NAME
+func applyArgs(args []string) error {
+	hasSlash := false
+	for _, arg := range args {
+		if strings.Contains(arg, "/") {
+			hasSlash = true
+		}
+	}
+
+	if hasSlash {
+		for _, arg := range args {
+			typ, name, ok := strings.Cut(arg, "/")
+			if !ok || typ == "" || name == "" {
+				return fmt.Errorf("arguments in TYPE/NAME form cannot be mixed with other forms, got '%s'", arg)
+			}
+			resourceArgs = append(resourceArgs, resourceArg{Type: typ, Name: name})
+		}
+		if allNamespaces {
+			return fmt.Errorf("a resource cannot be retrieved by name across all namespaces")
+		}
+		return nil
+	}
+
+	if len(args) > 2 {
+		return fmt.Errorf("expected TYPE[.group] [NAME] or TYPE[.group]/NAME arguments, got %d arguments", len(args))
+	}
+	if len(args) > 0 {
+		resourceType = args[0]
+	}
+	if len(args) > 1 {
+		if resourceName != "" || resourceRegex != "" {
+			return fmt.Errorf("NAME cannot be combined with --resource or --resource-regex")
+		}
+		resourceName = args[1]
+	}
+	return nil
+}
+
+// newRESTMapper builds a RESTMapper that also expands short names
+func newRESTMapper(client discovery.DiscoveryInterface) (meta.RESTMapper, error) {
+	groupResources, err := restmapper.GetAPIGroupResources(client)
+	if err != nil && len(groupResources) == 0 {
+		return nil, fmt.Errorf("failed to discover resource types: %v", err)
+	}
+	return restmapper.NewShortcutExpander(restmapper.NewDiscoveryRESTMapper(groupResources), client, nil), nil
+}
+
+// resolveResourceType resolves a TYPE[.group] argument to the resources it
+// names, through categories, short names, plurals and kinds as kubectl does
+func resolveResourceType(client discovery.DiscoveryInterface, arg string) (map[schema.GroupResource]bool, error) {
+	resources := make(map[schema.GroupResource]bool)
+
+	if grs, ok := restmapper.NewDiscoveryCategoryExpander(client).Expand(arg); ok && len(grs) > 0 {
+		for _, gr := range grs {
+			resources[gr] = true
+		}
+		return resources, nil
+	}
+
+	mapper, err := newRESTMapper(client)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := resolveMapping(mapper, arg)
+	if err != nil {
+		return nil, err
+	}
+	resources[mapping.Resource.GroupResource()] = true
+	return resources, nil
+}
+
+// resolveMapping resolves a single TYPE[.group] argument to its resource and scope
+func resolveMapping(mapper meta.RESTMapper, arg string) (*meta.RESTMapping, error) {
+	notFound := fmt.Errorf("the server doesn't have a resource type '%s'", arg)
+
+	fullySpecified, gr := schema.ParseResourceArg(strings.ToLower(arg))
+	gvr, err := mapper.ResourceFor(gr.WithVersion(""))
+	if fullySpecified != nil {
+		if fullGVR, fullErr := mapper.ResourceFor(*fullySpecified); fullErr == nil {
+			gvr, err = fullGVR, nil
+		}
+	}
+	if err != nil {
+		return nil, notFound
+	}
+
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return nil, notFound
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, notFound
+	}
+	return mapping, nil
+}
+
+// getNamedResources fetches the TYPE/NAME resources given as arguments
+// directly, without listing every composite in the cluster. Resources that
+// cannot be fetched are returned with their error. Namespaced resources are
+// looked up in namespace, or in the default namespace when it is empty.
+func getNamedResources(ctx context.Context, treeBuilder *tree.Builder, discoveryClient discovery.DiscoveryInterface, namespace string, progress io.Writer) ([]compositeItem, error) {
+	mapper, err := newRESTMapper(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	categories := restmapper.NewDiscoveryCategoryExpander(discoveryClient)
+
+	var items []compositeItem
+	// roots indexes items by resource key so resources sharing a root are diagnosed once
+	roots := make(map[string]int)
+	for _, arg := range resourceArgs {
+		mapping, err := resolveMapping(mapper, arg.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		objNamespace := ""
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			objNamespace = namespace
+		}
+
+		getCtx, getSpan := tracing.Start(ctx, "Get", attribute.String("k8s.resource", mapping.Resource.String()))
+		obj, err := treeBuilder.Get(getCtx, mapping.Resource, objNamespace, arg.Name)
+		tracing.End(getSpan, err)
+		if err != nil {
+			fmt.Fprintf(progress, "Error getting %s/%s: %v\n", arg.Type, arg.Name, err)
+			items = append(items, compositeItem{
+				GVR:       mapping.Resource,
+				Kind:      mapping.GroupVersionKind.Kind,
+				Namespace: objNamespace,
+				Name:      arg.Name,
+				Err:       fmt.Errorf("failed to get %s %s: %w", mapping.Resource.Resource, arg.Name, err),
+			})
+			continue
+		}
+
+		root := tree.Ref{GVR: mapping.Resource, Object: obj}
+		if walkOwners {
+			chain, err := treeBuilder.FindRoot(ctx, mapper, categories, mapping.Resource, obj.GetNamespace(), obj.GetName())
+			if err != nil {
+				fmt.Fprintf(progress, "Error finding owners of %s/%s: %v\n", arg.Type, arg.Name, err)
+			} else {
+				root = chain[0]
+			}
+		}
+
+		start := resourceKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		key := resourceKey(root.Object.GetKind(), root.Object.GetNamespace(), root.Object.GetName())
+		if i, ok := roots[key]; ok {
+			if key != start {
+				items[i].Highlight = append(items[i].Highlight, start)
+			}
+			continue
+		}
+
+		item := compositeItem{
+			GVR:         root.GVR,
+			Kind:        root.Object.GetKind(),
+			Namespace:   root.Object.GetNamespace(),
+			Name:        root.Object.GetName(),
+			Labels:      root.Object.GetLabels(),
+			Annotations: root.Object.GetAnnotations(),
+		}
+		if key != start {
+			fmt.Fprintf(progress, "%s/%s is owned by %s/%s\n", obj.GetKind(), obj.GetName(), item.Kind, item.Name)
+			item.Highlight = []string{start}
+		}
+		roots[key] = len(items)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&walkOwners, "owners", true, "Diagnose TYPE/NAME arguments from the claim or composite that owns them, highlighting the named resource")
+}
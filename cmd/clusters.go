@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+)
+
+var kubeContexts []string
+
+// resolveContexts expands the --contexts names and glob patterns against the
+// contexts of the kubeconfig, in sorted order
+func resolveContexts(patterns []string) ([]string, error) {
+	raw, err := kubeConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+
+	var available []string
+	for name := range raw.Contexts {
+		available = append(available, name)
+	}
+	sort.Strings(available)
+
+	selected := make(map[string]bool)
+	for _, pattern := range patterns {
+		found := false
+		for _, name := range available {
+			if matchGlob(pattern, name) {
+				selected[name] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no kubeconfig context matches '%s'", pattern)
+		}
+	}
+
+	var contexts []string
+	for _, name := range available {
+		if selected[name] {
+			contexts = append(contexts, name)
+		}
+	}
+	return contexts, nil
+}
+
+// contextConfig builds a client config for one kubeconfig context. Credential,
+// impersonation, TLS and timeout flags apply to every context; flags that pick
+// a single cluster or user do not.
+func contextConfig(name string) (*rest.Config, error) {
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.KubeConfig = kubeConfigFlags.KubeConfig
+	flags.Context = &name
+	flags.TLSServerName = kubeConfigFlags.TLSServerName
+	flags.Insecure = kubeConfigFlags.Insecure
+	flags.CertFile = kubeConfigFlags.CertFile
+	flags.KeyFile = kubeConfigFlags.KeyFile
+	flags.CAFile = kubeConfigFlags.CAFile
+	flags.BearerToken = kubeConfigFlags.BearerToken
+	flags.Impersonate = kubeConfigFlags.Impersonate
+	flags.ImpersonateUID = kubeConfigFlags.ImpersonateUID
+	flags.ImpersonateGroup = kubeConfigFlags.ImpersonateGroup
+	flags.Timeout = kubeConfigFlags.Timeout
+	flags.DisableCompression = kubeConfigFlags.DisableCompression
+	return flags.ToRESTConfig()
+}
+
+// runClusters diagnoses every context concurrently and tags each composite with
+// its context. A cluster that cannot be diagnosed is reported in the returned
+// map of errors and does not stop the others.
+func runClusters(ctx context.Context, contexts []string) (*diagnosis, map[string]string) {
+	results := make([]*diagnosis, len(contexts))
+	clusterErrors := make(map[string]string)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, name := range contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := diagnoseContext(ctx, name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error diagnosing cluster %s: %v\n", name, err)
+				mu.Lock()
+				clusterErrors[name] = err.Error()
+				mu.Unlock()
+				return
+			}
+			for j := range result.Results {
+				result.Results[j].Cluster = name
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	// Combine in context order so the output is stable between runs
+	combined := &diagnosis{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		combined.Results = append(combined.Results, result.Results...)
+		combined.APIErrors += result.APIErrors
+	}
+	return combined, clusterErrors
+}
+
+func diagnoseContext(ctx context.Context, name string) (*diagnosis, error) {
+	config, err := contextConfig(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build client config: %v", err)
+	}
+	namespace, err := targetNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+	return runDiagnosis(ctx, config, namespace, prefixWriter{w: os.Stderr, prefix: fmt.Sprintf("[%s] ", name)})
+}
+
+// clusterSummary returns the summary, grouped by cluster when several clusters were diagnosed
+func clusterSummary(data []report.CompositeData, contexts []string, clusterErrors map[string]string) (string, bool) {
+	if len(contexts) == 0 {
+		return report.GetSummary(data)
+	}
+	return report.GetClusterSummary(data, contexts, clusterErrors)
+}
+
+func init() {
+	rootCmd.Flags().StringSliceVar(&kubeContexts, "contexts", nil, "Diagnose these kubeconfig contexts concurrently (comma-separated, glob patterns allowed, '*' for all)")
+}
@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
+	"go.opentelemetry.io/otel/attribute"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// diagnosis holds the outcome of a single diagnosis run
+type diagnosis struct {
+	// Results are the top-level composites with their resource trees
+	Results []report.CompositeData
+	// APIErrors counts failed list and get calls against the API server
+	APIErrors int
+}
+
+// loadKubeConfig builds a client config from the kubectl-style connection
+// flags, $KUBECONFIG or ~/.kube/config, falling back to the in-cluster service
+// account when running inside a pod
+func loadKubeConfig() (*rest.Config, error) {
+	return kubeConfigFlags.ToRESTConfig()
+}
+
+// targetNamespace resolves the namespace to diagnose: --namespace, else the
+// namespace of the kubeconfig context (contextName, --context or the current
+// one), else empty for all namespaces
+func targetNamespace(contextName string) (string, error) {
+	if *kubeConfigFlags.Namespace != "" {
+		return *kubeConfigFlags.Namespace, nil
+	}
+	raw, err := kubeConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load kubeconfig: %v", err)
+	}
+	if contextName == "" {
+		contextName = *kubeConfigFlags.Context
+	}
+	if contextName == "" {
+		contextName = raw.CurrentContext
+	}
+	if kubeContext, ok := raw.Contexts[contextName]; ok {
+		return kubeContext.Namespace, nil
+	}
+	return "", nil
+}
+
+// runDiagnosis discovers the composites and claims selected by the filter flags,
+// or fetches the resources named as TYPE/NAME arguments, builds a tree for each
+// of them and hides composites that already appear as children of another tree.
+// namespace is the namespace resolved by targetNamespace, and progress receives
+// the status lines of the run.
+func runDiagnosis(ctx context.Context, config *rest.Config, namespace string, progress io.Writer) (_ *diagnosis, err error) {
+	ctx, span := tracing.Start(ctx, "Diagnose")
+	defer func() { tracing.End(span, err) }()
+
+	// The whole run, including retries, is bounded by --deadline
+	if runDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runDeadline)
+		defer cancel()
+	}
+
+	result := &diagnosis{}
+
+	// 1. Initialize Dynamic Client
+	dynClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+
+	// The same builder lists the composites and fetches the named resources, so
+	// they get the same retries and timeouts
+	treeBuilder := tree.NewBuilder(dynClient)
+	treeBuilder.Retries = requestRetries
+	if config.Timeout > 0 {
+		// --request-timeout already bounds each request
+		treeBuilder.RequestTimeout = config.Timeout
+	}
+	treeBuilder.MaxDepth = maxDepth
+	treeBuilder.StaleAfter = staleAfter
+	treeBuilder.EventsSince = eventsSince
+	treeBuilder.EventTypes = eventTypes
+
+	// 2. Discover and List all composites, or fetch the resources named as arguments
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %v", err)
+	}
+
+	var allItems []compositeItem
+	if len(resourceArgs) > 0 {
+		allItems, err = getNamedResources(ctx, treeBuilder, discoveryClient, namespace, progress)
+	} else {
+		allItems, err = listComposites(ctx, treeBuilder, discoveryClient, namespace, progress, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(progress, "Found %d composites. Building trees...\n", len(allItems))
+
+	var results []report.CompositeData
+
+	// 3. Build tree for each composite
+	for _, item := range allItems {
+		fmt.Fprintf(progress, "Analyzing %s/%s...\n", item.Kind, item.Name)
+
+		var root *report.ResourceStatus
+		err := item.Err
+		if err == nil {
+			root, err = treeBuilder.BuildTree(ctx, item.GVR, item.Namespace, item.Name)
+		}
+		errStr, errType := "", ""
+		if err != nil {
+			errStr = err.Error()
+			errType = tree.ErrorType(err)
+			// A missing resource is a finding rather than a failed API call
+			if errType != report.ErrorNotFound {
+				result.APIErrors++
+			}
+		} else {
+			result.APIErrors += countFetchErrors(root)
+			for _, key := range item.Highlight {
+				highlightNode(root, key)
+			}
+		}
+
+		results = append(results, report.CompositeData{
+			Name:        item.Name,
+			Namespace:   item.Namespace,
+			Kind:        item.Kind,
+			Labels:      item.Labels,
+			Annotations: item.Annotations,
+			Tree:        root,
+			Error:       errStr,
+			ErrorType:   errType,
+		})
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(progress, "Warning: the diagnosis did not finish within --deadline %s, results are partial\n", runDeadline)
+	}
+
+	// 4. Filter Redundant Resources
+	// Identify all resources that appear as children in any tree
+	childResources := make(map[string]bool)
+	for _, res := range results {
+		if res.Tree != nil {
+			collectChildren(res.Tree, childResources)
+		}
+	}
+
+	// Filter out top-level items that are children, unless they were asked for by name
+	for _, res := range results {
+		key := resourceKey(res.Kind, res.Namespace, res.Name)
+		if len(resourceArgs) > 0 || !childResources[key] {
+			result.Results = append(result.Results, res)
+		}
+	}
+
+	return result, nil
+}
+
+// prefixWriter prefixes every line written to w, so that the progress of
+// clusters diagnosed concurrently can be told apart
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+}
+
+func (p prefixWriter) Write(b []byte) (int, error) {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		if line != "" {
+			sb.WriteString(p.prefix)
+			sb.WriteString(line)
+		}
+	}
+	// A single write keeps the lines of concurrent runs from interleaving
+	if _, err := io.WriteString(p.w, sb.String()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// compositeItem is a resource whose tree is built
+type compositeItem struct {
+	GVR         schema.GroupVersionResource
+	Kind        string
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Highlight holds the resource keys of nodes to mark as starting points
+	Highlight []string
+	// Err is set when the resource could not be fetched
+	Err error
+}
+
+// listComposites discovers the composite and claim types selected by the
+// filter flags and lists their resources in namespace, or in all namespaces
+// when it is empty or --all-namespaces is set
+func listComposites(ctx context.Context, treeBuilder *tree.Builder, discoveryClient discovery.DiscoveryInterface, namespace string, progress io.Writer, result *diagnosis) ([]compositeItem, error) {
+	fmt.Fprintf(progress, "Discovering composite resources...\n")
+
+	nameFilter, err := newNameMatcher(resourceName, resourceRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeFilter map[schema.GroupResource]bool
+	if resourceType != "" {
+		typeFilter, err = resolveResourceType(discoveryClient, resourceType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listNamespace := namespace
+	if allNamespaces {
+		listNamespace = ""
+	}
+
+	// Find all GVRs with category "composite" or "claim"
+	type compositeType struct {
+		GVR        schema.GroupVersionResource
+		Namespaced bool
+	}
+	var compositeTypes []compositeType
+	_, discoverSpan := tracing.Start(ctx, "Discover")
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		tracing.End(discoverSpan, err)
+		return nil, fmt.Errorf("failed to fetch server groups: %v", err)
+	}
+
+	for _, group := range groups.Groups {
+		if !matchAPIGroup(apiGroup, group.Name) {
+			continue
+		}
+
+		for _, version := range group.Versions {
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+			resources, err := discoveryClient.ServerResourcesForGroupVersion(gv.String())
+			if err != nil {
+				// Ignore errors for specific versions (e.g. if CRD is broken)
+				continue
+			}
+
+			for _, r := range resources.APIResources {
+				// Skip subresources such as "xpostgresqlinstances/status"
+				if strings.Contains(r.Name, "/") {
+					continue
+				}
+				if resourceKind != "" && !strings.EqualFold(r.Kind, resourceKind) {
+					continue
+				}
+				if typeFilter != nil && !typeFilter[gv.WithResource(r.Name).GroupResource()] {
+					continue
+				}
+				if !hasCategory(r.Categories, discoveryCategories) {
+					continue
+				}
+				compositeTypes = append(compositeTypes, compositeType{
+					GVR:        gv.WithResource(r.Name),
+					Namespaced: r.Namespaced,
+				})
+			}
+		}
+	}
+
+	discoverSpan.SetAttributes(attribute.Int("crossplane.composite_types", len(compositeTypes)))
+	tracing.End(discoverSpan, nil)
+
+	if typeFilter != nil && len(compositeTypes) == 0 {
+		return nil, fmt.Errorf("'%s' is not a composite or claim type", resourceType)
+	}
+
+	fmt.Fprintf(progress, "Found %d composite types. Listing resources...\n", len(compositeTypes))
+
+	var allItems []compositeItem
+
+	listOpts := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+	}
+
+	for _, ct := range compositeTypes {
+		typeNamespace := ""
+		if ct.Namespaced {
+			typeNamespace = listNamespace
+		}
+
+		listCtx, listSpan := tracing.Start(ctx, "List", attribute.String("k8s.resource", ct.GVR.String()))
+		list, err := treeBuilder.List(listCtx, ct.GVR, typeNamespace, listOpts)
+		tracing.End(listSpan, err)
+		if err != nil {
+			fmt.Fprintf(progress, "Error listing %s: %v\n", ct.GVR.String(), err)
+			result.APIErrors++
+			continue
+		}
+
+		for _, item := range list.Items {
+			if !nameFilter.Match(item.GetName()) {
+				continue
+			}
+			allItems = append(allItems, compositeItem{
+				GVR:         ct.GVR,
+				Kind:        item.GetKind(),
+				Namespace:   item.GetNamespace(),
+				Name:        item.GetName(),
+				Labels:      item.GetLabels(),
+				Annotations: item.GetAnnotations(),
+			})
+		}
+	}
+
+	if len(allItems) == 0 && (nameFilter.IsSet() || resourceKind != "" || labelSelector != "" || apiGroup != "") {
+		var criteria []string
+		if nameFilter.IsSet() {
+			criteria = append(criteria, nameFilter.String())
+		}
+		if resourceKind != "" {
+			criteria = append(criteria, fmt.Sprintf("kind='%s'", resourceKind))
+		}
+		if apiGroup != "" {
+			criteria = append(criteria, fmt.Sprintf("api-group='%s'", apiGroup))
+		}
+		if labelSelector != "" {
+			criteria = append(criteria, fmt.Sprintf("selector='%s'", labelSelector))
+		}
+		fmt.Fprintf(progress, "Warning: No resources found matching %s.\n", strings.Join(criteria, " "))
+	}
+
+	return allItems, nil
+}
+
+// highlightNode marks the nodes identified by key as starting points
+func highlightNode(node *report.ResourceStatus, key string) {
+	if resourceKey(node.Kind, node.Namespace, node.Name) == key {
+		node.Highlighted = true
+	}
+	for i := range node.Children {
+		highlightNode(&node.Children[i], key)
+	}
+}
+
+// countFetchErrors counts children in the tree that could not be fetched.
+// Missing children are findings rather than failed API calls.
+func countFetchErrors(node *report.ResourceStatus) int {
+	count := 0
+	for _, child := range node.Children {
+		if strings.HasPrefix(child.Status, "Error fetching") && child.ErrorType != report.ErrorNotFound {
+			count++
+		}
+		count += countFetchErrors(&child)
+	}
+	return count
+}
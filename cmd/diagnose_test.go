@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{
+			name:   "single line",
+			writes: []string{"Found 3 composites. Building trees...\n"},
+			want:   "[prod] Found 3 composites. Building trees...\n",
+		},
+		{
+			name:   "several lines in one write",
+			writes: []string{"Analyzing XDatabase/a...\nAnalyzing XDatabase/b...\n"},
+			want:   "[prod] Analyzing XDatabase/a...\n[prod] Analyzing XDatabase/b...\n",
+		},
+		{
+			name:   "several writes",
+			writes: []string{"first\n", "second\n"},
+			want:   "[prod] first\n[prod] second\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			w := prefixWriter{w: &out, prefix: "[prod] "}
+			for _, s := range tt.writes {
+				n, err := fmt.Fprint(w, s)
+				if err != nil || n != len(s) {
+					t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(s))
+				}
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestListCompositesRetries(t *testing.T) {
+	xrGVR := schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "xdatabases"}
+	xr := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "example.org/v1", "kind": "XDatabase"}}
+	xr.SetName("db")
+
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "example.org/v1",
+		APIResources: []metav1.APIResource{{Name: "xdatabases", Kind: "XDatabase", Categories: []string{"composite"}}},
+	}}}}
+	dynClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{xrGVR: "XDatabaseList"}, xr)
+	calls := 0
+	dynClient.PrependReactor("list", "xdatabases", func(k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls == 1 {
+			return true, nil, apierrors.NewServiceUnavailable("restarting")
+		}
+		return false, nil, nil
+	})
+
+	treeBuilder := tree.NewBuilder(dynClient)
+	treeBuilder.Retries = 1
+	result := &diagnosis{}
+	items, err := listComposites(context.Background(), treeBuilder, discoveryClient, "", io.Discard, result)
+	if err != nil {
+		t.Fatalf("listComposites() error = %v", err)
+	}
+	if calls != 2 || result.APIErrors != 0 {
+		t.Errorf("got %d list calls and %d API errors, want 2 and 0", calls, result.APIErrors)
+	}
+	if len(items) != 1 || items[0].Name != "db" {
+		t.Errorf("items = %+v, want XDatabase db", items)
+	}
+}
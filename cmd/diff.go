@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/diff"
+)
+
+var diffOutput string
+
+// diffCmd compares two JSON reports
+var diffCmd = &cobra.Command{
+	Use:   "diff BEFORE.json AFTER.json",
+	Short: "Show what changed between two diagnosis reports",
+	Long: `diff compares two JSON reports produced with '--output json' and lists
+added and removed resources, health transitions, changed condition reasons
+and new events. Useful before and after upgrading providers or compositions.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		before, err := diff.LoadReport(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading report: %v\n", err)
+			return
+		}
+		after, err := diff.LoadReport(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading report: %v\n", err)
+			return
+		}
+
+		result := diff.Compare(before, after)
+
+		switch strings.ToLower(diffOutput) {
+		case "json":
+			err = diff.WriteJSON(os.Stdout, result)
+		case "text":
+			err = diff.WriteText(os.Stdout, result)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format '%s', defaulting to text\n", diffOutput)
+			err = diff.WriteText(os.Stdout, result)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing diff: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "text", "Output format (text, json)")
+	rootCmd.AddCommand(diffCmd)
+}
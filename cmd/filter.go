@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// nameMatcher matches resource names against a glob pattern or a regular expression
+type nameMatcher struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// newNameMatcher builds a matcher from the --resource and --resource-regex flags.
+// An empty glob and regex match every name.
+func newNameMatcher(glob, regex string) (*nameMatcher, error) {
+	m := &nameMatcher{glob: glob}
+
+	if glob != "" {
+		// Validate the pattern up front so a typo does not silently match nothing
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid resource pattern '%s': %v", glob, err)
+		}
+	}
+
+	if regex != "" {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource regex '%s': %v", regex, err)
+		}
+		m.regex = re
+	}
+
+	return m, nil
+}
+
+// IsSet reports whether the matcher filters anything at all
+func (m *nameMatcher) IsSet() bool {
+	return m.glob != "" || m.regex != nil
+}
+
+// Match reports whether name satisfies the configured pattern
+func (m *nameMatcher) Match(name string) bool {
+	if m.glob != "" && !matchGlob(m.glob, name) {
+		return false
+	}
+	if m.regex != nil && !m.regex.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// String describes the matcher for warning messages
+func (m *nameMatcher) String() string {
+	var parts []string
+	if m.glob != "" {
+		parts = append(parts, fmt.Sprintf("name='%s'", m.glob))
+	}
+	if m.regex != nil {
+		parts = append(parts, fmt.Sprintf("name=~'%s'", m.regex.String()))
+	}
+	return strings.Join(parts, " ")
+}
+
+// matchGlob matches value against a shell-style glob pattern.
+// Patterns without wildcards fall back to an exact comparison.
+func matchGlob(pattern, value string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern == value
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// matchAPIGroup reports whether an API group satisfies the --api-group filter.
+// The filter may be a glob, e.g. "*.example.org".
+func matchAPIGroup(pattern, group string) bool {
+	if pattern == "" {
+		return true
+	}
+	return matchGlob(strings.ToLower(pattern), strings.ToLower(group))
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/history"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+var (
+	historyDB        string
+	historyRetention time.Duration
+	historySince     time.Duration
+	historyOutput    string
+	minTransitions   int
+)
+
+// historyCmd groups the trend queries over recorded runs
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Query diagnosis runs recorded with --history-db",
+	Long: `history reports trends over diagnosis runs recorded with --history-db:
+how long composites have been unhealthy, which resources are flapping and
+the mean time to recovery per kind.`,
+}
+
+var historyUnhealthyCmd = &cobra.Command{
+	Use:   "unhealthy",
+	Short: "Show how long each composite has been unhealthy (or healthy)",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, ok := loadHistory()
+		if !ok {
+			return
+		}
+
+		nameFilter, err := newNameMatcher(resourceName, resourceRegex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+
+		var streaks []history.CompositeStreak
+		for _, s := range history.Streaks(runs) {
+			if !nameFilter.Match(s.Name) {
+				continue
+			}
+			if resourceKind != "" && !strings.EqualFold(s.Kind, resourceKind) {
+				continue
+			}
+			streaks = append(streaks, s)
+		}
+
+		writeHistory(streaks, func(w io.Writer) {
+			fmt.Fprintln(w, "COMPOSITE\tHEALTH\tSINCE\tDURATION")
+			for _, s := range streaks {
+				health := "Unhealthy"
+				if s.Healthy {
+					health = "Healthy"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Composite, health, s.Since.Local().Format(time.RFC3339), s.Duration.Round(time.Second))
+			}
+		})
+	},
+}
+
+var historyFlappingCmd = &cobra.Command{
+	Use:   "flapping",
+	Short: "Show resources whose health changed repeatedly",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, ok := loadHistory()
+		if !ok {
+			return
+		}
+
+		flaps := history.Flapping(runs, minTransitions)
+		writeHistory(flaps, func(w io.Writer) {
+			fmt.Fprintln(w, "RESOURCE\tKIND\tTRANSITIONS\tLAST STATUS")
+			for _, f := range flaps {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", f.Path, f.Kind, f.Transitions, f.LastStatus)
+			}
+		})
+	},
+}
+
+var historyMTTRCmd = &cobra.Command{
+	Use:   "mttr",
+	Short: "Show the mean time to recovery per resource kind",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, ok := loadHistory()
+		if !ok {
+			return
+		}
+
+		mttr := history.MTTRByKind(runs)
+		writeHistory(mttr, func(w io.Writer) {
+			fmt.Fprintln(w, "KIND\tRECOVERIES\tMTTR\tSTILL UNHEALTHY")
+			for _, m := range mttr {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", m.Kind, m.Recoveries, m.MTTR.Round(time.Second), m.Unresolved)
+			}
+		})
+	},
+}
+
+// loadHistory reads the runs within the --since window, printing any error
+func loadHistory() ([]history.Run, bool) {
+	if historyDB == "" {
+		fmt.Fprintf(os.Stderr, "Error: --history-db is required\n")
+		return nil, false
+	}
+
+	store, err := history.Open(historyDB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return nil, false
+	}
+	defer store.Close()
+
+	runs, err := store.Runs(time.Now().Add(-historySince))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return nil, false
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(os.Stderr, "No runs recorded in the last %s.\n", historySince)
+	}
+	return runs, true
+}
+
+// writeHistory writes v as JSON or renders it as a table, depending on --output
+func writeHistory(v interface{}, table func(w io.Writer)) {
+	switch strings.ToLower(historyOutput) {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing history: %v\n", err)
+		}
+	default:
+		writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		table(writer)
+		writer.Flush()
+	}
+}
+
+// recordHistory stores a run in the --history-db database and applies the retention period
+func recordHistory(runTime time.Time, data []report.CompositeData) error {
+	store, err := history.Open(historyDB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Record(history.Run{Time: runTime, Composites: data}); err != nil {
+		return fmt.Errorf("failed to record run: %v", err)
+	}
+	if historyRetention > 0 {
+		if _, err := store.Prune(runTime.Add(-historyRetention)); err != nil {
+			return fmt.Errorf("failed to prune history: %v", err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&historyDB, "history-db", "", "Record each run in this local history database (queried with 'history')")
+	rootCmd.PersistentFlags().DurationVar(&historyRetention, "history-retention", 30*24*time.Hour, "Delete recorded runs older than this (0 keeps everything)")
+
+	for _, name := range []string{"resource", "resource-regex", "kind"} {
+		historyCmd.PersistentFlags().AddFlag(filterFlags.Lookup(name))
+	}
+	historyCmd.PersistentFlags().DurationVar(&historySince, "since", 7*24*time.Hour, "Only consider runs recorded within this window")
+	historyCmd.PersistentFlags().StringVarP(&historyOutput, "output", "o", "table", "Output format (table, json)")
+	historyFlappingCmd.Flags().IntVar(&minTransitions, "min-transitions", 3, "Minimum number of health changes to report a resource as flapping")
+
+	historyCmd.AddCommand(historyUnhealthyCmd, historyFlappingCmd, historyMTTRCmd)
+	rootCmd.AddCommand(historyCmd)
+}
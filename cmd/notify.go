@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/vinishsoman/crossplane-diagnose/pkg/notify"
+)
+
+var (
+	notifyConfig  string
+	notifySlack   string
+	notifyTeams   string
+	notifyWebhook string
+	notifyState   string
+)
+
+// newNotifier builds a Notifier from --notify-config and the single-destination
+// --notify-* flags. It returns nil when no destination is configured.
+func newNotifier() (*notify.Notifier, error) {
+	cfg := &notify.Config{}
+	if notifyConfig != "" {
+		loaded, err := notify.LoadConfig(notifyConfig)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	if notifySlack != "" {
+		cfg.Routes = append(cfg.Routes, notify.Route{Name: "slack", Type: notify.TypeSlack, URL: notifySlack})
+	}
+	if notifyTeams != "" {
+		cfg.Routes = append(cfg.Routes, notify.Route{Name: "teams", Type: notify.TypeTeams, URL: notifyTeams})
+	}
+	if notifyWebhook != "" {
+		cfg.Routes = append(cfg.Routes, notify.Route{Name: "webhook", Type: notify.TypeWebhook, URL: notifyWebhook})
+	}
+	if notifyState != "" {
+		cfg.StateFile = notifyState
+	}
+
+	if len(cfg.Routes) == 0 {
+		return nil, nil
+	}
+	return notify.NewNotifier(cfg, nil), nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&notifyConfig, "notify-config", "", "YAML/JSON file with notification routes for unhealthy composites")
+	rootCmd.PersistentFlags().StringVar(&notifySlack, "notify-slack", "", "Slack incoming webhook URL to notify about unhealthy composites")
+	rootCmd.PersistentFlags().StringVar(&notifyTeams, "notify-teams", "", "Microsoft Teams webhook URL to notify about unhealthy composites")
+	rootCmd.PersistentFlags().StringVar(&notifyWebhook, "notify-webhook", "", "Generic webhook URL that receives unhealthy composites as JSON")
+	rootCmd.PersistentFlags().StringVar(&notifyState, "notify-state", "", "File remembering sent notifications so unchanged failures are not sent again")
+}
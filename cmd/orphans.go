@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/orphans"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+var orphansOutput string
+
+// orphansCmd finds managed resources left behind by their composites
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List managed resources that no composite references or owns",
+	Long: `orphans lists every managed resource (category "managed") and reports the
+ones that are not in the spec.resourceRefs of any composite and are not
+controlled by an existing owner, such as resources left behind after a
+failed deletion or a composition change.
+
+--kind, --api-group, --resource, --selector and --namespace select which
+managed resources are checked; all composites are always considered.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := loadKubeConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error building kubeconfig: %v\n", err)
+			return
+		}
+		dynClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create dynamic client: %v\n", err)
+			return
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create discovery client: %v\n", err)
+			return
+		}
+		mapper, err := newRESTMapper(discoveryClient)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		nameFilter, err := newNameMatcher(resourceName, resourceRegex)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+
+		namespace, err := targetNamespace("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+
+		// Lists and owner lookups are retried like a diagnosis run
+		treeBuilder := tree.NewBuilder(dynClient)
+		treeBuilder.Retries = requestRetries
+		if config.Timeout > 0 {
+			treeBuilder.RequestTimeout = config.Timeout
+		}
+
+		ctx := context.Background()
+		categories := restmapper.NewDiscoveryCategoryExpander(discoveryClient)
+
+		fmt.Fprintf(os.Stderr, "Listing composites...\n")
+		composites, failed := listCategory(ctx, treeBuilder, mapper, categories, "composite", "", false)
+		if failed > 0 {
+			// A missing composite would turn its resources into false orphans
+			fmt.Fprintf(os.Stderr, "Error: failed to list all composites\n")
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "Listing managed resources...\n")
+		managed, _ := listCategory(ctx, treeBuilder, mapper, categories, "managed", namespace, true)
+		var selected []unstructured.Unstructured
+		for _, mr := range managed {
+			if nameFilter.Match(mr.GetName()) {
+				selected = append(selected, mr)
+			}
+		}
+
+		found, unchecked := orphans.Find(composites, selected, ownerLookup(ctx, treeBuilder, mapper))
+		fmt.Fprintf(os.Stderr, "Checked %d managed resources against %d composites, found %d orphaned.\n", len(selected), len(composites), len(found))
+		if unchecked > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %d managed resources whose owner could not be checked\n", unchecked)
+		}
+
+		switch strings.ToLower(orphansOutput) {
+		case "json":
+			err = orphans.WriteJSON(os.Stdout, found)
+		case "table":
+			err = orphans.WriteTable(os.Stdout, found, time.Now())
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format '%s', defaulting to table\n", orphansOutput)
+			err = orphans.WriteTable(os.Stdout, found, time.Now())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing orphans: %v\n", err)
+		}
+	},
+}
+
+// listCategory lists every resource of the types in a category and returns how
+// many types could not be listed. With filtered set, the --kind, --api-group
+// and --selector flags apply and namespaced types are listed in namespace.
+func listCategory(ctx context.Context, treeBuilder *tree.Builder, mapper meta.RESTMapper, categories restmapper.CategoryExpander, category, namespace string, filtered bool) ([]unstructured.Unstructured, int) {
+	grs, _ := categories.Expand(category)
+
+	listOpts := metav1.ListOptions{}
+	listNamespace := ""
+	if filtered {
+		listOpts.LabelSelector = labelSelector
+		listOpts.FieldSelector = fieldSelector
+		listNamespace = namespace
+		if allNamespaces {
+			listNamespace = ""
+		}
+	}
+
+	var items []unstructured.Unstructured
+	failed := 0
+	for _, gr := range grs {
+		if filtered && !matchAPIGroup(apiGroup, gr.Group) {
+			continue
+		}
+		mapping, err := resolveMapping(mapper, gr.String())
+		if err != nil {
+			continue
+		}
+		if filtered && resourceKind != "" && !strings.EqualFold(mapping.GroupVersionKind.Kind, resourceKind) {
+			continue
+		}
+
+		typeNamespace := ""
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			typeNamespace = listNamespace
+		}
+		list, err := treeBuilder.List(ctx, mapping.Resource, typeNamespace, listOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing %s: %v\n", mapping.Resource.String(), err)
+			failed++
+			continue
+		}
+		items = append(items, list.Items...)
+	}
+	return items, failed
+}
+
+// ownerLookup checks owners with a get. An owner whose type is no longer
+// served, that is not found or that was recreated with a new UID is gone.
+func ownerLookup(ctx context.Context, treeBuilder *tree.Builder, mapper meta.RESTMapper) orphans.OwnerLookup {
+	return func(owner metav1.OwnerReference, namespace string) (bool, error) {
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err != nil {
+			return false, fmt.Errorf("invalid owner apiVersion '%s': %v", owner.APIVersion, err)
+		}
+		mapping, err := mapper.RESTMapping(gv.WithKind(owner.Kind).GroupKind(), gv.Version)
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			namespace = ""
+		}
+
+		obj, err := treeBuilder.Get(ctx, mapping.Resource, namespace, owner.Name)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting %s %s: %v\n", owner.Kind, owner.Name, err)
+			return false, err
+		}
+		return obj.GetUID() == owner.UID, nil
+	}
+}
+
+func init() {
+	orphansCmd.Flags().AddFlagSet(filterFlags)
+	orphansCmd.Flags().AddFlag(diagnosisFlags.Lookup("retries"))
+	orphansCmd.Flags().StringVarP(&orphansOutput, "output", "o", "table", "Output format (table, json)")
+	rootCmd.AddCommand(orphansCmd)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/publish"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	writeConfigMap string
+	writeReport    string
+
+	manifestName      string
+	manifestNamespace string
+	manifestCRD       bool
+	manifestCronJob   bool
+	manifestImage     string
+	manifestSchedule  string
+	manifestGroups    []string
+)
+
+// parseTarget parses a NAMESPACE/NAME flag value
+func parseTarget(flag, value string) (*publish.Target, error) {
+	if value == "" {
+		return nil, nil
+	}
+	ns, name, ok := strings.Cut(value, "/")
+	if !ok || ns == "" || name == "" {
+		return nil, fmt.Errorf("--%s must be NAMESPACE/NAME, got '%s'", flag, value)
+	}
+	return &publish.Target{Namespace: ns, Name: name}, nil
+}
+
+// publishResults writes the report to the ConfigMap and DiagnosisReport
+// selected with --write-configmap and --write-report
+func publishResults(ctx context.Context, config *rest.Config, runTime time.Time, data []report.CompositeData) error {
+	cmTarget, err := parseTarget("write-configmap", writeConfigMap)
+	if err != nil {
+		return err
+	}
+	reportTarget, err := parseTarget("write-report", writeReport)
+	if err != nil {
+		return err
+	}
+	if cmTarget == nil && reportTarget == nil {
+		return nil
+	}
+
+	dynClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+	writer := publish.NewWriter(dynClient)
+
+	var errs []error
+	if cmTarget != nil {
+		errs = append(errs, writer.WriteConfigMap(ctx, cmTarget.Namespace, cmTarget.Name, runTime, data))
+	}
+	if reportTarget != nil {
+		errs = append(errs, writer.WriteReport(ctx, reportTarget.Namespace, reportTarget.Name, runTime, data))
+	}
+	return errors.Join(errs...)
+}
+
+// discoverReadGroups returns the API groups that define composite, claim or
+// managed resource types in the current cluster
+func discoverReadGroups() ([]string, error) {
+	config, err := loadKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %v", err)
+	}
+	_, resourceLists, err := discoveryClient.ServerGroupsAndResources()
+	if err != nil && len(resourceLists) == 0 {
+		return nil, fmt.Errorf("failed to discover resource types: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	var groups []string
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || gv.Group == "" || seen[gv.Group] {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if hasCategory(r.Categories, []string{"composite", "claim", "managed"}) {
+				seen[gv.Group] = true
+				groups = append(groups, gv.Group)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+// manifestsCmd prints what is needed to run the diagnosis inside the cluster
+var manifestsCmd = &cobra.Command{
+	Use:   "manifests",
+	Short: "Print RBAC, CronJob and CRD manifests for running in-cluster",
+	Long: `manifests prints a ServiceAccount with read-only access to events, the
+Crossplane API groups and the API groups of composites, claims and managed
+resources, write access to the targets of --write-configmap and --write-report,
+and optionally the DiagnosisReport CRD and a CronJob running the diagnosis.
+
+The API groups to read are discovered from the current cluster unless given
+with --read-api-groups. Secrets and other core resources are never readable.
+
+Filter, history, notification and report flags given to this command are passed
+on to the CronJob; connection flags other than --namespace are not:
+
+  crossplane-diagnose manifests --cronjob --crd --image my-registry/crossplane-diagnose:v1 \
+    --write-report crossplane-system/latest -l team=payments | kubectl apply -f -`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmTarget, err := parseTarget("write-configmap", writeConfigMap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		reportTarget, err := parseTarget("write-report", writeReport)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		if manifestCronJob && manifestImage == "" {
+			fmt.Fprintf(os.Stderr, "Error: --image is required with --cronjob\n")
+			return
+		}
+
+		readGroups := manifestGroups
+		if len(readGroups) == 0 {
+			readGroups, err = discoverReadGroups()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v (use --read-api-groups to list them explicitly)\n", err)
+				return
+			}
+		}
+
+		// Pass the diagnosis flags set on the command line on to the CronJob
+		jobArgs := cronJobArgs(cmd)
+
+		err = publish.WriteManifests(os.Stdout, publish.ManifestOptions{
+			Name:       manifestName,
+			Namespace:  manifestNamespace,
+			ReadGroups: readGroups,
+			ConfigMap:  cmTarget,
+			Report:     reportTarget,
+			CRD:        manifestCRD,
+			CronJob:    manifestCronJob,
+			Image:      manifestImage,
+			Schedule:   manifestSchedule,
+			Args:       jobArgs,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating manifests: %v\n", err)
+		}
+	},
+}
+
+// cronJobArgs returns the flags set on the manifests command line that the
+// CronJob's diagnosis run needs, in --flag=value form
+func cronJobArgs(cmd *cobra.Command) []string {
+	var args []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		// The manifests command's own flags describe the manifests, not the diagnosis
+		isDiagnosisFlag := filterFlags.Lookup(f.Name) != nil || diagnosisFlags.Lookup(f.Name) != nil
+		if cmd.LocalNonPersistentFlags().Lookup(f.Name) != nil && !isDiagnosisFlag {
+			return
+		}
+		// The CronJob connects with its service account
+		if f.Name != "namespace" && kubeConnectionFlags.Lookup(f.Name) != nil {
+			return
+		}
+		value := f.Value.String()
+		// Slice values print as [a,b], which would be parsed as a single element
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			value = strings.Join(slice.GetSlice(), ",")
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+	return args
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&writeConfigMap, "write-configmap", "", "Write the latest report to this ConfigMap (NAMESPACE/NAME)")
+	rootCmd.PersistentFlags().StringVar(&writeReport, "write-report", "", "Write the latest report to this DiagnosisReport custom resource (NAMESPACE/NAME)")
+
+	manifestsCmd.Flags().AddFlagSet(filterFlags)
+	manifestsCmd.Flags().AddFlagSet(diagnosisFlags)
+	manifestsCmd.Flags().StringVar(&manifestName, "name", "crossplane-diagnose", "Name of the ServiceAccount, roles and CronJob")
+	manifestsCmd.Flags().StringVar(&manifestNamespace, "install-namespace", "crossplane-system", "Namespace of the ServiceAccount and CronJob")
+	manifestsCmd.Flags().BoolVar(&manifestCRD, "crd", false, "Include the DiagnosisReport CustomResourceDefinition")
+	manifestsCmd.Flags().BoolVar(&manifestCronJob, "cronjob", false, "Include a CronJob running the diagnosis")
+	manifestsCmd.Flags().StringVar(&manifestImage, "image", "", "Container image for the CronJob")
+	manifestsCmd.Flags().StringSliceVar(&manifestGroups, "read-api-groups", nil, "API groups of the composites, claims and managed resources to grant read access to (default discovered from the cluster)")
+	manifestsCmd.Flags().StringVar(&manifestSchedule, "schedule", "*/15 * * * *", "Cron schedule of the CronJob")
+	rootCmd.AddCommand(manifestsCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// captureStdout returns what run writes to os.Stdout
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+	run()
+	w.Close()
+	return string(<-done)
+}
+
+func TestManifestsCronJobArgs(t *testing.T) {
+	out := captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"manifests", "--cronjob", "--image", "example/crossplane-diagnose",
+			"--read-api-groups", "example.org", "--event-types", "Warning,Normal", "--kind", "XDatabase", "--name", "diag"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+
+	var args []string
+	for _, doc := range bytes.Split([]byte(out), []byte("\n---\n")) {
+		var m struct {
+			Kind string `json:"kind"`
+			Spec struct {
+				JobTemplate struct {
+					Spec struct {
+						Template struct {
+							Spec struct {
+								Containers []struct {
+									Args []string `json:"args"`
+								} `json:"containers"`
+							} `json:"spec"`
+						} `json:"template"`
+					} `json:"spec"`
+				} `json:"jobTemplate"`
+			} `json:"spec"`
+		}
+		if err := yaml.Unmarshal(doc, &m); err != nil {
+			t.Fatalf("invalid YAML: %v\n%s", err, doc)
+		}
+		if m.Kind == "CronJob" {
+			args = m.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Args
+		}
+	}
+
+	want := map[string]bool{"--event-types=Warning,Normal": true, "--kind=XDatabase": true}
+	for _, arg := range args {
+		if arg == "--name=diag" || arg == "--cronjob=true" {
+			t.Errorf("manifests flag %s passed on to the CronJob", arg)
+		}
+		delete(want, arg)
+	}
+	if len(want) > 0 {
+		t.Errorf("CronJob args %q are missing %v", args, want)
+	}
+}
@@ -7,218 +7,155 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/vinishsoman/crossplane-diagnose/pkg/ai"
 	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
 	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
 )
 
 var (
-	outputFormat string
-	aiAnalysis   bool
-	resourceName string
-	resourceKind string
-	aiProvider   string
+	outputFormat   string
+	aiAnalysis     bool
+	resourceName   string
+	resourceRegex  string
+	resourceKind   string
+	aiProvider     string
+	labelSelector  string
+	fieldSelector  string
+	allNamespaces  bool
+	apiGroup       string
+	onlyUnhealthy  bool
+	asciiTree      bool
+	otlpEndpoint   string
+	traceFile      string
+	maxDepth       int
+	requestRetries int
+	runDeadline    time.Duration
+	staleAfter     time.Duration
+	eventsSince    time.Duration
+	eventTypes     []string
+
+	// kubeConfigFlags are the kubectl connection flags (--kubeconfig, --context,
+	// --as, --request-timeout, --namespace, ...) used to build the client config
+	kubeConfigFlags = genericclioptions.NewConfigFlags(true)
+	// kubeConnectionFlags holds the flags registered by kubeConfigFlags
+	kubeConnectionFlags = pflag.NewFlagSet("kubernetes", pflag.ContinueOnError)
+	// filterFlags select the resources to diagnose, and diagnosisFlags tune how
+	// trees are built. Each is added only to the commands that honor it.
+	filterFlags    = newFilterFlags()
+	diagnosisFlags = newDiagnosisFlags()
+
+	// shutdownTracing flushes spans recorded during the run
+	shutdownTracing = func(context.Context) error { return nil }
 )
 
+// discoveryCategories are the resource categories that are diagnosed as tree roots
+var discoveryCategories = []string{"composite", "claim"}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "crossplane-diagnose",
+	Use:   "crossplane-diagnose [TYPE[.group] [NAME] | TYPE[.group]/NAME ...]",
 	Short: "A CLI tool to diagnose Crossplane issues",
 	Long: `crossplane-diagnose is a CLI tool designed to help you identify and resolve 
 issues with your Crossplane installation and resources. It builds a resource tree 
-for each Composite Resource and generates a detailed report.`,
-	Args: cobra.NoArgs,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Fprintf(os.Stderr, "Starting Crossplane diagnosis...\n")
-
-		// 1. Initialize Dynamic Client
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			if home := homedir.HomeDir(); home != "" {
-				kubeconfig = filepath.Join(home, ".kube", "config")
-			}
-		}
-
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+for each Composite Resource and generates a detailed report.
+
+Like kubectl get, an optional TYPE (resource, short name, kind or category,
+optionally qualified with its API group) and NAME limit the diagnosis, and
+TYPE/NAME arguments fetch just those resources without listing the cluster:
+
+  crossplane-diagnose xpostgresqlinstances.database.example.org my-db
+  crossplane-diagnose claim -n payments
+  crossplane-diagnose xpostgresqlinstance/my-db xbucket/assets`,
+	Args: cobra.ArbitraryArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		shutdown, err := tracing.Setup(context.Background(), otlpEndpoint, traceFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error building kubeconfig: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: tracing disabled: %v\n", err)
 			return
 		}
-
-		dynClient, err := dynamic.NewForConfig(config)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating dynamic client: %v\n", err)
-			return
+		shutdownTracing = shutdown
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if err := shutdownTracing(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error flushing traces: %v\n", err)
 		}
-
-		treeBuilder := tree.NewBuilder(dynClient)
-
-		// 2. Discover and List all composites
-		fmt.Fprintf(os.Stderr, "Discovering composite resources...\n")
-
-		discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating discovery client: %v\n", err)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyArgs(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
 		}
 
-		// Find all GVRs with category "composite"
-		var compositeGVRs []schema.GroupVersionResource
-		groups, err := discoveryClient.ServerGroups()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error fetching server groups: %v\n", err)
-			return
-		}
+		fmt.Fprintf(os.Stderr, "Starting Crossplane diagnosis...\n")
 
-		for _, group := range groups.Groups {
-			for _, version := range group.Versions {
-				gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
-				resources, err := discoveryClient.ServerResourcesForGroupVersion(gv.String())
-				if err != nil {
-					// Ignore errors for specific versions (e.g. if CRD is broken)
-					continue
-				}
-
-				for _, r := range resources.APIResources {
-					for _, category := range r.Categories {
-						if category == "composite" {
-							compositeGVRs = append(compositeGVRs, gv.WithResource(r.Name))
-							break
-						}
-					}
-				}
+		var (
+			config        *rest.Config
+			result        *diagnosis
+			contexts      []string
+			clusterErrors map[string]string
+		)
+		runTime := time.Now()
+
+		if len(kubeContexts) > 0 {
+			var err error
+			contexts, err = resolveContexts(kubeContexts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return
 			}
-		}
-
-		fmt.Fprintf(os.Stderr, "Found %d composite types. Listing resources...\n", len(compositeGVRs))
-
-		type CompositeItem struct {
-			APIVersion string
-			Kind       string
-			Name       string
-		}
-		var allItems []CompositeItem
-
-		for _, gvr := range compositeGVRs {
-			list, err := dynClient.Resource(gvr).List(context.Background(), metav1.ListOptions{})
+			fmt.Fprintf(os.Stderr, "Diagnosing %d clusters: %s\n", len(contexts), strings.Join(contexts, ", "))
+			result, clusterErrors = runClusters(context.Background(), contexts)
+		} else {
+			var err error
+			config, err = loadKubeConfig()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error listing %s: %v\n", gvr.String(), err)
-				continue
+				fmt.Fprintf(os.Stderr, "Error building kubeconfig: %v\n", err)
+				return
 			}
 
-			for _, item := range list.Items {
-				allItems = append(allItems, CompositeItem{
-					APIVersion: item.GetAPIVersion(),
-					Kind:       item.GetKind(),
-					Name:       item.GetName(),
-				})
+			namespace, err := targetNamespace("")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return
 			}
-		}
 
-		// Filter list if resourceName or resourceKind is provided
-		if resourceName != "" || resourceKind != "" {
-			var filteredItems []CompositeItem
-			found := false
-			for _, item := range allItems {
-				match := true
-				if resourceName != "" && item.Name != resourceName {
-					match = false
-				}
-				if resourceKind != "" && !strings.EqualFold(item.Kind, resourceKind) {
-					match = false
-				}
-
-				if match {
-					filteredItems = append(filteredItems, item)
-					found = true
-				}
+			result, err = runDiagnosis(context.Background(), config, namespace, os.Stderr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return
 			}
+		}
+		filteredResults := result.Results
 
-			if !found {
-				msg := "No resources found matching"
-				if resourceName != "" {
-					msg += fmt.Sprintf(" name='%s'", resourceName)
-				}
-				if resourceKind != "" {
-					msg += fmt.Sprintf(" kind='%s'", resourceKind)
-				}
-				fmt.Fprintf(os.Stderr, "Warning: %s.\n", msg)
-				allItems = []CompositeItem{}
-			} else {
-				allItems = filteredItems
+		if historyDB != "" {
+			if err := recordHistory(runTime, filteredResults); err != nil {
+				fmt.Fprintf(os.Stderr, "Error recording history: %v\n", err)
 			}
 		}
 
-		fmt.Fprintf(os.Stderr, "Found %d composites. Building trees...\n", len(allItems))
-
-		var results []report.CompositeData
-
-		// 3. Build tree for each composite
-		for _, item := range allItems {
-			fmt.Fprintf(os.Stderr, "Analyzing %s/%s...\n", item.Kind, item.Name)
-
-			gv, err := schema.ParseGroupVersion(item.APIVersion)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "  Error parsing APIVersion %s: %v\n", item.APIVersion, err)
-				continue
+		if writeConfigMap != "" || writeReport != "" {
+			// With --contexts the report is written to the current context
+			var err error
+			if config == nil {
+				config, err = loadKubeConfig()
 			}
-
-			// We need the plural resource.
-			// Since we listed "composite", these are XRs.
-			// We can guess plural or just use the Kind if we had a mapper.
-			// For the initial fetch of the XR, we can use the GVR if we knew the resource name.
-			// But wait, we already have the item from kubectl.
-			// We can just pass the GVR to BuildTree.
-			// To get the GVR resource name (plural), we can try lowercase + s.
-			resource := strings.ToLower(item.Kind) + "s"
-
-			gvr := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: resource,
+			if err == nil {
+				err = publishResults(context.Background(), config, runTime, filteredResults)
 			}
-
-			root, err := treeBuilder.BuildTree(context.Background(), gvr, item.Name)
-			errStr := ""
 			if err != nil {
-				errStr = err.Error()
-			}
-
-			results = append(results, report.CompositeData{
-				Name:  item.Name,
-				Kind:  item.Kind,
-				Tree:  root,
-				Error: errStr,
-			})
-		}
-
-		// 4. Filter Redundant Resources
-		// Identify all resources that appear as children in any tree
-		childResources := make(map[string]bool)
-		for _, res := range results {
-			if res.Tree != nil {
-				collectChildren(res.Tree, childResources)
+				fmt.Fprintf(os.Stderr, "Error writing report to the cluster: %v\n", err)
 			}
 		}
 
-		// Filter out top-level items that are children
-		var filteredResults []report.CompositeData
-		for _, res := range results {
-			key := fmt.Sprintf("%s/%s", res.Kind, res.Name)
-			if !childResources[key] {
-				filteredResults = append(filteredResults, res)
-			} else {
-				// Optional: Log that we are hiding a resource?
-				// fmt.Fprintf(os.Stderr, "Hiding child resource from top-level: %s\n", key)
-			}
+		if onlyUnhealthy {
+			filteredResults = report.PruneHealthy(filteredResults)
 		}
 
 		// 5. Generate Report
@@ -230,6 +167,19 @@ for each Composite Resource and generates a detailed report.`,
 			genErr = report.GenerateCSV(os.Stdout, filteredResults)
 		case "table":
 			genErr = report.GenerateTable(os.Stdout, filteredResults)
+		case "tree":
+			genErr = report.GenerateTree(os.Stdout, filteredResults, report.TreeOptions{
+				Color: useColor(os.Stdout),
+				ASCII: asciiTree,
+			})
+		case "markdown", "md":
+			genErr = report.GenerateMarkdown(os.Stdout, filteredResults)
+		case "html":
+			genErr = report.GenerateHTML(os.Stdout, filteredResults)
+		case "junit":
+			genErr = report.GenerateJUnit(os.Stdout, filteredResults)
+		case "sarif":
+			genErr = report.GenerateSARIF(os.Stdout, filteredResults)
 		default:
 			fmt.Fprintf(os.Stderr, "Unknown output format '%s', defaulting to JSON\n", outputFormat)
 			genErr = report.GenerateJSON(os.Stdout, filteredResults)
@@ -240,9 +190,18 @@ for each Composite Resource and generates a detailed report.`,
 		}
 
 		// 6. Print Summary and AI Analysis
-		summary, hasFailures := report.GetSummary(filteredResults)
+		summary, hasFailures := clusterSummary(filteredResults, contexts, clusterErrors)
 		fmt.Fprint(os.Stderr, summary)
 
+		notifier, err := newNotifier()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error configuring notifications: %v\n", err)
+		} else if notifier != nil {
+			if err := notifier.Notify(context.Background(), filteredResults); err != nil {
+				fmt.Fprintf(os.Stderr, "Error sending notifications: %v\n", err)
+			}
+		}
+
 		if hasFailures && aiAnalysis {
 			fmt.Fprintf(os.Stderr, "\n🤖 Sending failure summary to %s for analysis...\n", aiProvider)
 
@@ -271,25 +230,97 @@ for each Composite Resource and generates a detailed report.`,
 
 func collectChildren(node *report.ResourceStatus, children map[string]bool) {
 	for _, child := range node.Children {
-		key := fmt.Sprintf("%s/%s", child.Kind, child.Name)
-		children[key] = true
+		// A reference back to an ancestor does not make it a child
+		if child.Status == "Cycle" {
+			continue
+		}
+		children[resourceKey(child.Kind, child.Namespace, child.Name)] = true
 		collectChildren(&child, children)
 	}
 }
 
+// useColor reports whether colored output should be written to f.
+// Colors are disabled when f is not a terminal or NO_COLOR is set.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// resourceKey identifies a resource across trees for de-duplication
+func resourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// hasCategory reports whether any of the wanted categories is present
+func hasCategory(categories, wanted []string) bool {
+	for _, category := range categories {
+		for _, w := range wanted {
+			if category == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// Installed as kubectl-crossplane_diagnose, kubectl runs us as a plugin
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "kubectl-") {
+		rootCmd.Annotations = map[string]string{cobra.CommandDisplayNameAnnotation: "kubectl crossplane-diagnose"}
+	}
+
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// newFilterFlags registers the flags that select the resources to diagnose
+func newFilterFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("filters", pflag.ContinueOnError)
+	flags.StringVarP(&resourceName, "resource", "r", "", "Name of the composite resources to diagnose (supports glob patterns, e.g. 'payments-*')")
+	flags.StringVar(&resourceRegex, "resource-regex", "", "Regular expression matched against composite resource names")
+	flags.StringVarP(&resourceKind, "kind", "k", "", "Kind of the composite resources to diagnose (case-insensitive)")
+	flags.StringVarP(&labelSelector, "selector", "l", "", "Label selector to filter composites and claims (e.g. 'team=payments')")
+	flags.StringVar(&fieldSelector, "field-selector", "", "Field selector to filter composites and claims (e.g. 'metadata.name=my-db')")
+	flags.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Diagnose namespaced composites and claims across all namespaces (overrides --namespace)")
+	flags.StringVar(&apiGroup, "api-group", "", "Only diagnose composites in this API group (supports glob patterns, e.g. '*.example.org')")
+	return flags
+}
+
+// newDiagnosisFlags registers the flags that tune how resource trees are built
+func newDiagnosisFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("diagnosis", pflag.ContinueOnError)
+	flags.IntVar(&requestRetries, "retries", tree.DefaultRetries, "Retry API requests that fail with throttling, server errors, timeouts or dropped connections this many times")
+	flags.DurationVar(&runDeadline, "deadline", 0, "Stop a diagnosis run after this long and report the partial results (0 for no limit)")
+	flags.IntVar(&maxDepth, "max-depth", tree.DefaultMaxDepth, "Follow resource references at most this many levels below each composite (0 for no limit)")
+	flags.DurationVar(&staleAfter, "stale-after", tree.DefaultStaleAfter, "Report resources whose controller has not observed their latest generation for this long as Stale")
+	flags.DurationVar(&eventsSince, "since", 0, "Only include events last seen within this window, e.g. 1h (0 includes all)")
+	flags.StringSliceVar(&eventTypes, "event-types", nil, "Only include events of these types, e.g. Warning (default all types)")
+	return flags
+}
+
 func init() {
-	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format (json, csv, table)")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "json", "Output format (json, csv, table, tree, markdown, html, junit, sarif)")
+	rootCmd.Flags().BoolVar(&asciiTree, "ascii", false, "Draw the tree output with ASCII instead of Unicode characters")
 	rootCmd.Flags().BoolVar(&aiAnalysis, "ai-analysis", false, "Send failure summary to AI provider for analysis")
 	rootCmd.Flags().StringVar(&aiProvider, "ai-provider", "claude", "AI provider to use for analysis (claude)")
-	rootCmd.Flags().StringVarP(&resourceName, "resource", "r", "", "Name of the specific composite resource to diagnose")
-	rootCmd.Flags().StringVarP(&resourceKind, "kind", "k", "", "Kind of the composite resources to diagnose (case-insensitive)")
+	rootCmd.Flags().BoolVar(&onlyUnhealthy, "only-unhealthy", false, "Only report unhealthy resources and the path from their top parent")
+
+	rootCmd.Flags().AddFlagSet(filterFlags)
+	rootCmd.Flags().AddFlagSet(diagnosisFlags)
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export OpenTelemetry traces of the run to this OTLP/HTTP endpoint (e.g. http://localhost:4318)")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "Write OpenTelemetry traces of the run to this file as JSON")
+
+	kubeConfigFlags.AddFlags(kubeConnectionFlags)
+	kubeConnectionFlags.Lookup("namespace").Usage = "Only diagnose namespaced composites and claims in this namespace"
+	rootCmd.PersistentFlags().AddFlagSet(kubeConnectionFlags)
 }
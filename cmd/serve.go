@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/metrics"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+var (
+	serveMetrics  bool
+	listenAddress string
+	runInterval   time.Duration
+)
+
+// serveCmd runs the diagnosis periodically and exposes the results
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Periodically diagnose Crossplane and expose the results",
+	Long: `serve runs the diagnosis in a loop and exposes the latest results as
+Prometheus metrics on /metrics, so Crossplane health can be alerted on
+without writing a separate exporter.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !serveMetrics {
+			fmt.Fprintf(os.Stderr, "Error: nothing to serve, enable --metrics\n")
+			return
+		}
+
+		config, err := loadKubeConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error building kubeconfig: %v\n", err)
+			return
+		}
+		namespace, err := targetNamespace("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		exporter := metrics.NewExporter()
+
+		notifier, err := newNotifier()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error configuring notifications: %v\n", err)
+			return
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter.Handler())
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		server := &http.Server{Addr: listenAddress, Handler: mux}
+		go func() {
+			fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics\n", listenAddress)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fmt.Fprintf(os.Stderr, "Error serving metrics: %v\n", err)
+				stop()
+			}
+		}()
+
+		ticker := time.NewTicker(runInterval)
+		defer ticker.Stop()
+
+		for {
+			start := time.Now()
+			result, err := runDiagnosis(ctx, config, namespace, os.Stderr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			} else {
+				exporter.Update(result.Results, time.Since(start), result.APIErrors)
+				if historyDB != "" {
+					if err := recordHistory(start, result.Results); err != nil {
+						fmt.Fprintf(os.Stderr, "Error recording history: %v\n", err)
+					}
+				}
+				if err := publishResults(ctx, config, start, result.Results); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing report to the cluster: %v\n", err)
+				}
+				summary, _ := report.GetSummary(result.Results)
+				fmt.Fprint(os.Stderr, summary)
+				if notifier != nil {
+					if err := notifier.Notify(ctx, result.Results); err != nil {
+						fmt.Fprintf(os.Stderr, "Error sending notifications: %v\n", err)
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := server.Shutdown(shutdownCtx); err != nil {
+					fmt.Fprintf(os.Stderr, "Error shutting down server: %v\n", err)
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().AddFlagSet(filterFlags)
+	serveCmd.Flags().AddFlagSet(diagnosisFlags)
+	serveCmd.Flags().BoolVar(&serveMetrics, "metrics", false, "Expose diagnosis results as Prometheus metrics on /metrics")
+	serveCmd.Flags().StringVar(&listenAddress, "listen-address", ":9090", "Address to serve metrics on")
+	serveCmd.Flags().DurationVar(&runInterval, "interval", time.Minute, "Interval between diagnosis runs")
+	rootCmd.AddCommand(serveCmd)
+}
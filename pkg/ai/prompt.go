@@ -20,6 +20,7 @@ CONTEXT:
 INSTRUCTIONS:
 1. Analyze the "Reason" for each failure.
 2. Identify the likely root cause (e.g., if "ReconcilePaused", explain it's an annotation).
+   Resources with status "Paused" or "ObserveOnly" are deliberately not reconciled; only mention them if they explain another failure.
 3. Suggest specific 'kubectl' commands to inspect relevant resources (e.g., 'kubectl describe <kind> <name>', 'kubectl get events').
 4. If applicable, suggest YAML fixes or configuration changes.
 5. Be concise and prioritize the most critical failures.
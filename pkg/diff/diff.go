@@ -0,0 +1,252 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+// eventCount matches the repeat count of an aggregated event, e.g. " (x3)",
+// which changes without the event being new
+var eventCount = regexp.MustCompile(` \(x\d+\)$`)
+
+// Change types reported by Compare
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeHealth  = "health"
+	ChangeReason  = "reason"
+	ChangeEvent   = "event"
+)
+
+// Change describes a single difference between two diagnosis reports
+type Change struct {
+	Type      string `json:"type"`
+	Composite string `json:"composite"`
+	Path      string `json:"path"`
+	Condition string `json:"condition,omitempty"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// Result holds every change between two reports
+type Result struct {
+	Changes []Change `json:"changes"`
+}
+
+// node is a flattened tree node keyed by its path from the composite
+type node struct {
+	composite string
+	path      string
+	status    *report.ResourceStatus
+}
+
+// LoadReport reads a JSON report produced by report.GenerateJSON
+func LoadReport(path string) ([]report.CompositeData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data []report.CompositeData
+	if err := json.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// Compare returns the added and removed nodes, health transitions, changed
+// condition reasons and new events between two reports
+func Compare(before, after []report.CompositeData) *Result {
+	beforeNodes, beforeOrder := flatten(before)
+	afterNodes, afterOrder := flatten(after)
+
+	result := &Result{Changes: []Change{}}
+
+	for _, key := range afterOrder {
+		a := afterNodes[key]
+		b, ok := beforeNodes[key]
+		if !ok {
+			result.Changes = append(result.Changes, Change{
+				Type:      ChangeAdded,
+				Composite: a.composite,
+				Path:      a.path,
+				After:     a.status.Status,
+			})
+			continue
+		}
+		result.Changes = append(result.Changes, compareNode(b, a)...)
+	}
+
+	for _, key := range beforeOrder {
+		if _, ok := afterNodes[key]; ok {
+			continue
+		}
+		b := beforeNodes[key]
+		result.Changes = append(result.Changes, Change{
+			Type:      ChangeRemoved,
+			Composite: b.composite,
+			Path:      b.path,
+			Before:    b.status.Status,
+		})
+	}
+
+	return result
+}
+
+func compareNode(before, after node) []Change {
+	var changes []Change
+
+	if before.status.Status != after.status.Status {
+		changes = append(changes, Change{
+			Type:      ChangeHealth,
+			Composite: after.composite,
+			Path:      after.path,
+			Before:    before.status.Status,
+			After:     after.status.Status,
+		})
+	}
+
+	beforeReasons := conditionReasons(before.status)
+	afterReasons := conditionReasons(after.status)
+	var types []string
+	for condType := range afterReasons {
+		types = append(types, condType)
+	}
+	sort.Strings(types)
+	for _, condType := range types {
+		oldReason, ok := beforeReasons[condType]
+		if ok && oldReason != afterReasons[condType] {
+			changes = append(changes, Change{
+				Type:      ChangeReason,
+				Composite: after.composite,
+				Path:      after.path,
+				Condition: condType,
+				Before:    oldReason,
+				After:     afterReasons[condType],
+			})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, event := range before.status.Events {
+		seen[eventCount.ReplaceAllString(event, "")] = true
+	}
+	for _, event := range after.status.Events {
+		if !seen[eventCount.ReplaceAllString(event, "")] {
+			changes = append(changes, Change{
+				Type:      ChangeEvent,
+				Composite: after.composite,
+				Path:      after.path,
+				After:     event,
+			})
+		}
+	}
+
+	return changes
+}
+
+// conditionReasons maps each condition type of the node to its reason
+func conditionReasons(status *report.ResourceStatus) map[string]string {
+	reasons := make(map[string]string)
+	for _, c := range status.Conditions {
+		if cond, ok := report.ParseCondition(c); ok {
+			reasons[cond.Type] = cond.Reason
+		}
+	}
+	return reasons
+}
+
+// flatten indexes every node of every composite by its path, preserving tree order
+func flatten(data []report.CompositeData) (map[string]node, []string) {
+	nodes := make(map[string]node)
+	var order []string
+
+	add := func(n node) {
+		if _, ok := nodes[n.path]; ok {
+			return
+		}
+		nodes[n.path] = n
+		order = append(order, n.path)
+	}
+
+	var walk func(composite, parentPath string, status *report.ResourceStatus)
+	walk = func(composite, parentPath string, status *report.ResourceStatus) {
+		path := report.ResourcePath(parentPath, status.Kind, status.Namespace, status.Name)
+		add(node{composite: composite, path: path, status: status})
+		for i := range status.Children {
+			walk(composite, path, &status.Children[i])
+		}
+	}
+
+	for _, d := range data {
+		composite := report.CompositeName(d)
+		if d.Tree == nil {
+			// Represent composites that failed to build as a single errored node
+			add(node{composite: composite, path: report.CompositePath(d), status: &report.ResourceStatus{
+				Kind:      d.Kind,
+				Namespace: d.Namespace,
+				Name:      d.Name,
+				Status:    "Error: " + d.Error,
+			}})
+			continue
+		}
+		walk(composite, d.Cluster, d.Tree)
+	}
+	return nodes, order
+}
+
+// WriteText writes the changes in a human readable form grouped by composite
+func WriteText(w io.Writer, result *Result) error {
+	if len(result.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	var order []string
+	byComposite := make(map[string][]Change)
+	for _, c := range result.Changes {
+		if _, ok := byComposite[c.Composite]; !ok {
+			order = append(order, c.Composite)
+		}
+		byComposite[c.Composite] = append(byComposite[c.Composite], c)
+	}
+
+	for _, composite := range order {
+		if _, err := fmt.Fprintln(w, composite); err != nil {
+			return err
+		}
+		for _, c := range byComposite[composite] {
+			var line string
+			switch c.Type {
+			case ChangeAdded:
+				line = fmt.Sprintf("  + %s (%s)", c.Path, c.After)
+			case ChangeRemoved:
+				line = fmt.Sprintf("  - %s (%s)", c.Path, c.Before)
+			case ChangeHealth:
+				line = fmt.Sprintf("  ~ %s: %s -> %s", c.Path, c.Before, c.After)
+			case ChangeReason:
+				line = fmt.Sprintf("  ~ %s: %s reason %s -> %s", c.Path, c.Condition, c.Before, c.After)
+			case ChangeEvent:
+				line = fmt.Sprintf("  ! %s: new event %s", c.Path, c.After)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the changes as JSON
+func WriteJSON(w io.Writer, result *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+func composite(children ...report.ResourceStatus) []report.CompositeData {
+	return []report.CompositeData{{Kind: "XDatabase", Name: "db", Tree: &report.ResourceStatus{
+		Kind: "XDatabase", Name: "db", Status: "Available", Children: children,
+	}}}
+}
+
+func TestCompare(t *testing.T) {
+	instance := report.ResourceStatus{Kind: "Instance", Name: "i", Status: "Available", Conditions: []string{"Ready=True (Available): "}}
+
+	tests := []struct {
+		name   string
+		before []report.CompositeData
+		after  []report.CompositeData
+		want   []Change
+	}{
+		{
+			name:   "identical",
+			before: composite(instance),
+			after:  composite(instance),
+			want:   []Change{},
+		},
+		{
+			name:   "added and removed nodes",
+			before: composite(instance),
+			after:  composite(report.ResourceStatus{Kind: "Bucket", Name: "b", Status: "Unhealthy"}),
+			want: []Change{
+				{Type: ChangeAdded, Composite: "XDatabase/db", Path: "XDatabase/db > Bucket/b", After: "Unhealthy"},
+				{Type: ChangeRemoved, Composite: "XDatabase/db", Path: "XDatabase/db > Instance/i", Before: "Available"},
+			},
+		},
+		{
+			name:   "health and reason change",
+			before: composite(instance),
+			after: composite(report.ResourceStatus{Kind: "Instance", Name: "i", Status: "Unhealthy",
+				Conditions: []string{"Ready=False (ReconcileError): cannot connect"}}),
+			want: []Change{
+				{Type: ChangeHealth, Composite: "XDatabase/db", Path: "XDatabase/db > Instance/i", Before: "Available", After: "Unhealthy"},
+				{Type: ChangeReason, Composite: "XDatabase/db", Path: "XDatabase/db > Instance/i", Condition: "Ready", Before: "Available", After: "ReconcileError"},
+			},
+		},
+		{
+			name: "new events ignore repeat counts",
+			before: composite(report.ResourceStatus{Kind: "Instance", Name: "i", Status: "Unhealthy",
+				Events: []string{"[Warning] CannotConnect: timeout (x2)"}}),
+			after: composite(report.ResourceStatus{Kind: "Instance", Name: "i", Status: "Unhealthy",
+				Events: []string{"[Warning] CannotConnect: timeout (x9)", "[Warning] CannotObserve: denied"}}),
+			want: []Change{
+				{Type: ChangeEvent, Composite: "XDatabase/db", Path: "XDatabase/db > Instance/i", After: "[Warning] CannotObserve: denied"},
+			},
+		},
+		{
+			name:   "composite that could not be fetched",
+			before: composite(),
+			after:  []report.CompositeData{{Kind: "XDatabase", Name: "db", Error: "forbidden"}},
+			want: []Change{
+				{Type: ChangeHealth, Composite: "XDatabase/db", Path: "XDatabase/db", Before: "Available", After: "Error: forbidden"},
+			},
+		},
+		{
+			name: "composite recovering on one of several clusters",
+			before: []report.CompositeData{
+				{Cluster: "prod", Kind: "XDatabase", Name: "db", Error: "forbidden"},
+				{Cluster: "staging", Kind: "XDatabase", Name: "db", Tree: &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available"}},
+			},
+			after: []report.CompositeData{
+				{Cluster: "prod", Kind: "XDatabase", Name: "db", Tree: &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available"}},
+				{Cluster: "staging", Kind: "XDatabase", Name: "db", Tree: &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available"}},
+			},
+			want: []Change{
+				{Type: ChangeHealth, Composite: "prod: XDatabase/db", Path: "prod > XDatabase/db", Before: "Error: forbidden", After: "Available"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.before, tt.after).Changes
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	bolt "go.etcd.io/bbolt"
+)
+
+// runsBucket holds one entry per diagnosis run keyed by its RFC 3339 run time
+var runsBucket = []byte("runs")
+
+// keyFormat sorts lexically in chronological order
+const keyFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Run is a single stored diagnosis run
+type Run struct {
+	Time       time.Time              `json:"time"`
+	Composites []report.CompositeData `json:"composites"`
+}
+
+// Store persists diagnosis runs in a local bolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the history database at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(runsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores the results of a run
+func (s *Store) Record(run Run) error {
+	value, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	key := []byte(run.Time.UTC().Format(keyFormat))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).Put(key, value)
+	})
+}
+
+// Prune deletes runs recorded before cutoff and returns how many were removed
+func (s *Store) Prune(cutoff time.Time) (int, error) {
+	var removed int
+	limit := []byte(cutoff.UTC().Format(keyFormat))
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(runsBucket)
+
+		// Collect keys first, deleting while iterating a cursor skips entries
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < string(limit); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	return removed, err
+}
+
+// Runs returns the runs recorded at or after since, oldest first
+func (s *Store) Runs(since time.Time) ([]Run, error) {
+	var runs []Run
+	start := []byte(since.UTC().Format(keyFormat))
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("failed to decode run %s: %v", k, err)
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+// CompositeStreak describes how long a composite has been in its current health state
+type CompositeStreak struct {
+	Composite string        `json:"composite"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Healthy   bool          `json:"healthy"`
+	Since     time.Time     `json:"since"`
+	Duration  time.Duration `json:"duration"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+// Flap describes a resource whose health changed repeatedly
+type Flap struct {
+	Composite   string `json:"composite"`
+	Path        string `json:"path"`
+	Kind        string `json:"kind"`
+	Transitions int    `json:"transitions"`
+	LastStatus  string `json:"last_status"`
+}
+
+// KindMTTR is the mean time to recovery of resources of one kind
+type KindMTTR struct {
+	Kind       string        `json:"kind"`
+	Recoveries int           `json:"recoveries"`
+	MTTR       time.Duration `json:"mttr"`
+	Unresolved int           `json:"unresolved"`
+}
+
+// nodeState is the health of a single resource in one run
+type nodeState struct {
+	composite string
+	kind      string
+	status    string
+}
+
+// Streaks returns the current health streak of every composite seen in runs,
+// unhealthy composites first and longest streaks first
+func Streaks(runs []Run) []CompositeStreak {
+	streaks := make(map[string]*CompositeStreak)
+
+	for _, run := range runs {
+		for _, d := range run.Composites {
+			key := report.CompositePath(d)
+			healthy := report.GetStats([]report.CompositeData{d}).UnhealthyComposites == 0
+
+			s, ok := streaks[key]
+			if !ok || s.Healthy != healthy {
+				s = &CompositeStreak{Composite: report.CompositeName(d), Kind: d.Kind, Name: d.Name, Healthy: healthy, Since: run.Time}
+				streaks[key] = s
+			}
+			s.LastSeen = run.Time
+		}
+	}
+
+	var result []CompositeStreak
+	for _, s := range streaks {
+		s.Duration = s.LastSeen.Sub(s.Since)
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Healthy != result[j].Healthy {
+			return !result[i].Healthy
+		}
+		if result[i].Duration != result[j].Duration {
+			return result[i].Duration > result[j].Duration
+		}
+		return result[i].Composite < result[j].Composite
+	})
+	return result
+}
+
+// Flapping returns resources whose health changed at least minTransitions times
+func Flapping(runs []Run, minTransitions int) []Flap {
+	last := make(map[string]nodeState)
+	flaps := make(map[string]*Flap)
+
+	for _, run := range runs {
+		for path, state := range indexNodes(run) {
+			f, ok := flaps[path]
+			if !ok {
+				f = &Flap{Composite: state.composite, Path: path, Kind: state.kind}
+				flaps[path] = f
+			}
+			if prev, seen := last[path]; seen && report.IsFailure(prev.status) != report.IsFailure(state.status) {
+				f.Transitions++
+			}
+			f.LastStatus = state.status
+			last[path] = state
+		}
+	}
+
+	var result []Flap
+	for _, f := range flaps {
+		if f.Transitions >= minTransitions {
+			result = append(result, *f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Transitions != result[j].Transitions {
+			return result[i].Transitions > result[j].Transitions
+		}
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// MTTRByKind returns the mean time from a resource becoming unhealthy to
+// recovering, per resource kind. Resources still unhealthy in the latest run
+// they appear in are counted as unresolved.
+func MTTRByKind(runs []Run) []KindMTTR {
+	unhealthySince := make(map[string]time.Time)
+	kinds := make(map[string]string)
+	type total struct {
+		count    int
+		duration time.Duration
+	}
+	totals := make(map[string]*total)
+
+	for _, run := range runs {
+		for path, state := range indexNodes(run) {
+			kinds[path] = state.kind
+			since, failing := unhealthySince[path]
+			healthy := !report.IsFailure(state.status)
+
+			switch {
+			case !healthy && !failing:
+				unhealthySince[path] = run.Time
+			case healthy && failing:
+				t, ok := totals[state.kind]
+				if !ok {
+					t = &total{}
+					totals[state.kind] = t
+				}
+				t.count++
+				t.duration += run.Time.Sub(since)
+				delete(unhealthySince, path)
+			}
+		}
+	}
+
+	byKind := make(map[string]*KindMTTR)
+	get := func(kind string) *KindMTTR {
+		m, ok := byKind[kind]
+		if !ok {
+			m = &KindMTTR{Kind: kind}
+			byKind[kind] = m
+		}
+		return m
+	}
+	for kind, t := range totals {
+		m := get(kind)
+		m.Recoveries = t.count
+		m.MTTR = t.duration / time.Duration(t.count)
+	}
+	for path := range unhealthySince {
+		get(kinds[path]).Unresolved++
+	}
+
+	var result []KindMTTR
+	for _, m := range byKind {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MTTR != result[j].MTTR {
+			return result[i].MTTR > result[j].MTTR
+		}
+		return result[i].Kind < result[j].Kind
+	})
+	return result
+}
+
+// indexNodes maps the path of every resource in a run to its state
+func indexNodes(run Run) map[string]nodeState {
+	nodes := make(map[string]nodeState)
+
+	var walk func(composite, parentPath string, node *report.ResourceStatus)
+	walk = func(composite, parentPath string, node *report.ResourceStatus) {
+		path := report.ResourcePath(parentPath, node.Kind, node.Namespace, node.Name)
+		nodes[path] = nodeState{composite: composite, kind: node.Kind, status: node.Status}
+		for i := range node.Children {
+			walk(composite, path, &node.Children[i])
+		}
+	}
+
+	for _, d := range run.Composites {
+		composite := report.CompositeName(d)
+		if d.Tree == nil {
+			nodes[report.CompositePath(d)] = nodeState{composite: composite, kind: d.Kind, status: "Error"}
+			continue
+		}
+		walk(composite, d.Cluster, d.Tree)
+	}
+	return nodes
+}
@@ -0,0 +1,150 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// run builds a run at hour h of composite XDatabase/db with one Instance child per status
+func run(h int, rootStatus string, childStatuses ...string) Run {
+	root := &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: rootStatus}
+	for _, status := range childStatuses {
+		root.Children = append(root.Children, report.ResourceStatus{Kind: "Instance", Name: "i", Status: status})
+	}
+	return Run{
+		Time:       start.Add(time.Duration(h) * time.Hour),
+		Composites: []report.CompositeData{{Kind: "XDatabase", Name: "db", Tree: root}},
+	}
+}
+
+// clusterRun builds a run at hour h of composite XDatabase/db on two clusters,
+// failing to fetch it on prod with fetchError unless that is empty
+func clusterRun(h int, fetchError string) Run {
+	prod := report.CompositeData{Cluster: "prod", Kind: "XDatabase", Name: "db", Error: fetchError}
+	if fetchError == "" {
+		prod.Tree = &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available"}
+	}
+	return Run{
+		Time: start.Add(time.Duration(h) * time.Hour),
+		Composites: []report.CompositeData{prod, {Cluster: "staging", Kind: "XDatabase", Name: "db",
+			Tree: &report.ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available"}}},
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []Run
+		want []CompositeStreak
+	}{
+		{
+			name: "unhealthy since the last transition",
+			runs: []Run{run(0, "Unhealthy"), run(1, "Available"), run(2, "Unhealthy"), run(5, "Unhealthy")},
+			want: []CompositeStreak{{Composite: "XDatabase/db", Kind: "XDatabase", Name: "db", Healthy: false,
+				Since: start.Add(2 * time.Hour), Duration: 3 * time.Hour, LastSeen: start.Add(5 * time.Hour)}},
+		},
+		{
+			name: "healthy throughout",
+			runs: []Run{run(0, "Available"), run(4, "Available")},
+			want: []CompositeStreak{{Composite: "XDatabase/db", Kind: "XDatabase", Name: "db", Healthy: true,
+				Since: start, Duration: 4 * time.Hour, LastSeen: start.Add(4 * time.Hour)}},
+		},
+		{
+			name: "recovered from a fetch error",
+			runs: []Run{clusterRun(0, "forbidden"), clusterRun(1, ""), clusterRun(3, "")},
+			want: []CompositeStreak{
+				{Composite: "staging: XDatabase/db", Kind: "XDatabase", Name: "db", Healthy: true,
+					Since: start, Duration: 3 * time.Hour, LastSeen: start.Add(3 * time.Hour)},
+				{Composite: "prod: XDatabase/db", Kind: "XDatabase", Name: "db", Healthy: true,
+					Since: start.Add(time.Hour), Duration: 2 * time.Hour, LastSeen: start.Add(3 * time.Hour)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Streaks(tt.runs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Streaks() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlapping(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []Run
+		want []Flap
+	}{
+		{
+			name: "failures and recoveries are transitions",
+			runs: []Run{
+				run(0, "Available", "Available"),
+				run(1, "Available", "Unhealthy"),
+				run(2, "Available", "Available"),
+				run(3, "Available", "Stale"),
+			},
+			want: []Flap{{Composite: "XDatabase/db", Path: "XDatabase/db > Instance/i", Kind: "Instance", Transitions: 3, LastStatus: "Stale"}},
+		},
+		{
+			name: "moving between non-failure statuses is not flapping",
+			runs: []Run{
+				run(0, "Available", "Available"),
+				run(1, "Available", "Progressing"),
+				run(2, "Available", "Paused"),
+				run(3, "Available", "ObserveOnly"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Flapping(tt.runs, 3); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Flapping() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMTTRByKind(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []Run
+		want []KindMTTR
+	}{
+		{
+			name: "mean of recoveries per kind",
+			runs: []Run{
+				run(0, "Available", "Unhealthy"),
+				run(2, "Available", "Available"),
+				run(3, "Available", "Unhealthy"),
+				run(7, "Available", "Available"),
+			},
+			want: []KindMTTR{{Kind: "Instance", Recoveries: 2, MTTR: 3 * time.Hour}},
+		},
+		{
+			name: "still failing is unresolved",
+			runs: []Run{run(0, "Unhealthy"), run(1, "Unhealthy")},
+			want: []KindMTTR{{Kind: "XDatabase", Unresolved: 1}},
+		},
+		{
+			name: "composite recovering from a fetch error on one cluster",
+			runs: []Run{clusterRun(0, "forbidden"), clusterRun(2, "forbidden"), clusterRun(3, "")},
+			want: []KindMTTR{{Kind: "XDatabase", Recoveries: 1, MTTR: 3 * time.Hour}},
+		},
+		{
+			name: "deliberate and progressing resources are not failing",
+			runs: []Run{run(0, "Paused", "Progressing"), run(1, "ObserveOnly", "Available")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MTTRByKind(tt.runs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MTTRByKind() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
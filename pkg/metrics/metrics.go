@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+const namespace = "crossplane_diagnose"
+
+var compositeLabels = []string{"kind", "namespace", "name"}
+
+// Exporter exposes the results of the latest diagnosis run as Prometheus metrics
+type Exporter struct {
+	registry *prometheus.Registry
+	mu       sync.Mutex
+
+	compositeHealthy       *prometheus.GaugeVec
+	compositeResources     *prometheus.GaugeVec
+	compositeUnhealthy     *prometheus.GaugeVec
+	compositeDepth         *prometheus.GaugeVec
+	compositeTransitionAge *prometheus.GaugeVec
+	kindComposites         *prometheus.GaugeVec
+	duration               prometheus.Gauge
+	apiErrors              prometheus.Gauge
+	lastRun                prometheus.Gauge
+	runs                   prometheus.Counter
+}
+
+// NewExporter creates an Exporter with its own registry
+func NewExporter() *Exporter {
+	e := &Exporter{
+		registry: prometheus.NewRegistry(),
+		compositeHealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "composite_healthy",
+			Help:      "Whether every resource in the composite's tree is healthy (1) or not (0).",
+		}, compositeLabels),
+		compositeResources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "composite_resources",
+			Help:      "Number of resources in the composite's tree, including the composite itself.",
+		}, compositeLabels),
+		compositeUnhealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "composite_unhealthy_resources",
+			Help:      "Number of unhealthy resources in the composite's tree.",
+		}, compositeLabels),
+		compositeDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "composite_tree_depth",
+			Help:      "Depth of the composite's resource tree.",
+		}, compositeLabels),
+		compositeTransitionAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "composite_last_transition_age_seconds",
+			Help:      "Seconds since the composite's most recent condition transition.",
+		}, compositeLabels),
+		kindComposites: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "kind_composites",
+			Help:      "Number of composites per kind and health.",
+		}, []string{"kind", "health"}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_duration_seconds",
+			Help:      "Duration of the latest diagnosis run.",
+		}),
+		apiErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_api_errors",
+			Help:      "Number of failed API requests during the latest diagnosis run.",
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix time at which the latest diagnosis run finished.",
+		}),
+		runs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "runs_total",
+			Help:      "Total number of diagnosis runs.",
+		}),
+	}
+
+	e.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		e.compositeHealthy,
+		e.compositeResources,
+		e.compositeUnhealthy,
+		e.compositeDepth,
+		e.compositeTransitionAge,
+		e.kindComposites,
+		e.duration,
+		e.apiErrors,
+		e.lastRun,
+		e.runs,
+	)
+	return e
+}
+
+// Handler returns the HTTP handler serving /metrics
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Update replaces the per-composite metrics with the results of a diagnosis run.
+// Composites that no longer exist disappear from the exposition.
+func (e *Exporter) Update(data []report.CompositeData, duration time.Duration, apiErrors int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+
+	e.compositeHealthy.Reset()
+	e.compositeResources.Reset()
+	e.compositeUnhealthy.Reset()
+	e.compositeDepth.Reset()
+	e.compositeTransitionAge.Reset()
+	e.kindComposites.Reset()
+
+	for _, d := range data {
+		labels := prometheus.Labels{"kind": d.Kind, "namespace": d.Namespace, "name": d.Name}
+		stats := report.GetStats([]report.CompositeData{d})
+
+		healthy := 0.0
+		health := "unhealthy"
+		if stats.UnhealthyComposites == 0 {
+			healthy = 1
+			health = "healthy"
+		}
+		e.compositeHealthy.With(labels).Set(healthy)
+		e.compositeResources.With(labels).Set(float64(stats.Resources))
+		e.compositeUnhealthy.With(labels).Set(float64(stats.UnhealthyResources))
+		e.kindComposites.WithLabelValues(d.Kind, health).Inc()
+
+		if d.Tree != nil {
+			e.compositeDepth.With(labels).Set(float64(treeDepth(d.Tree)))
+			if d.Tree.LastTransitionTime != nil {
+				e.compositeTransitionAge.With(labels).Set(now.Sub(*d.Tree.LastTransitionTime).Seconds())
+			}
+		}
+	}
+
+	e.duration.Set(duration.Seconds())
+	e.apiErrors.Set(float64(apiErrors))
+	e.lastRun.Set(float64(now.Unix()))
+	e.runs.Inc()
+}
+
+// treeDepth returns the number of levels in the tree rooted at node
+func treeDepth(node *report.ResourceStatus) int {
+	depth := 0
+	for _, child := range node.Children {
+		if d := treeDepth(&child); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
@@ -0,0 +1,310 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"sigs.k8s.io/yaml"
+)
+
+// Supported route types
+const (
+	TypeSlack   = "slack"
+	TypeTeams   = "teams"
+	TypeWebhook = "webhook"
+)
+
+// Config describes where notifications are sent
+type Config struct {
+	// Routes are evaluated independently; a composite is sent to every matching route
+	Routes []Route `json:"routes"`
+	// StateFile remembers what was already sent so repeated runs do not notify twice
+	StateFile string `json:"stateFile,omitempty"`
+}
+
+// Route sends failures of matching composites to one destination
+type Route struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// MatchLabels and MatchAnnotations select composites; empty matches everything
+	MatchLabels      map[string]string `json:"matchLabels,omitempty"`
+	MatchAnnotations map[string]string `json:"matchAnnotations,omitempty"`
+}
+
+// Payload is the body posted to generic JSON webhooks
+type Payload struct {
+	Route      string                 `json:"route"`
+	Summary    string                 `json:"summary"`
+	Composites []report.CompositeData `json:"composites"`
+}
+
+// LoadConfig reads a YAML or JSON notification config
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse notification config %s: %v", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that every route has a known type and a URL
+func (c *Config) Validate() error {
+	for i, r := range c.Routes {
+		if r.URL == "" {
+			return fmt.Errorf("route %d (%s): url is required", i, r.Name)
+		}
+		switch r.Type {
+		case TypeSlack, TypeTeams, TypeWebhook:
+		default:
+			return fmt.Errorf("route %d (%s): unknown type '%s' (slack, teams, webhook)", i, r.Name, r.Type)
+		}
+	}
+	return nil
+}
+
+// Notifier posts unhealthy composites to the configured routes
+type Notifier struct {
+	config *Config
+	client *http.Client
+	// state maps route name to composite key to the fingerprint last sent
+	state map[string]map[string]string
+}
+
+// NewNotifier creates a Notifier. A nil client uses a client with a 10s timeout.
+func NewNotifier(config *Config, client *http.Client) *Notifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Notifier{config: config, client: client}
+}
+
+// Notify sends each route the unhealthy composites it matches that were not
+// already sent with the same failures. Composites that recovered are forgotten,
+// so a later failure notifies again.
+func (n *Notifier) Notify(ctx context.Context, data []report.CompositeData) error {
+	if n.state == nil {
+		state, err := loadState(n.config.StateFile)
+		if err != nil {
+			return err
+		}
+		n.state = state
+	}
+	state := n.state
+
+	var errs []error
+	for i, route := range n.config.Routes {
+		routeKey := route.Name
+		if routeKey == "" {
+			routeKey = fmt.Sprintf("route-%d", i)
+		}
+		sent := state[routeKey]
+		next := make(map[string]string)
+
+		var pending []report.CompositeData
+		for _, d := range data {
+			if !route.matches(d) {
+				continue
+			}
+			stats := report.GetStats([]report.CompositeData{d})
+			if stats.UnhealthyComposites == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s", d.Kind, d.Namespace, d.Name)
+			if d.Cluster != "" {
+				key = d.Cluster + "/" + key
+			}
+			fp := fingerprint(d)
+			next[key] = fp
+			if sent[key] != fp {
+				pending = append(pending, d)
+			}
+		}
+
+		if len(pending) > 0 {
+			if err := n.send(ctx, routeKey, route, pending); err != nil {
+				errs = append(errs, fmt.Errorf("route %s: %v", routeKey, err))
+				// Keep the previous state so the failed notification is retried
+				next = sent
+			}
+		}
+		state[routeKey] = next
+	}
+
+	if err := saveState(n.config.StateFile, state); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, routeName string, route Route, data []report.CompositeData) error {
+	summary, _ := report.GetSummary(data)
+
+	var body interface{}
+	switch route.Type {
+	case TypeSlack:
+		body = map[string]string{
+			"text": fmt.Sprintf(":rotating_light: *Crossplane diagnosis found %d unhealthy composite(s)*\n```%s```", len(data), summary),
+		}
+	case TypeTeams:
+		body = teamsMessage(len(data), summary)
+	default:
+		body = Payload{Route: routeName, Summary: summary, Composites: data}
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.URL, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// teamsMessage wraps the summary in an Adaptive Card as accepted by Teams workflow webhooks
+func teamsMessage(count int, summary string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]interface{}{
+					{
+						"type":   "TextBlock",
+						"text":   fmt.Sprintf("Crossplane diagnosis found %d unhealthy composite(s)", count),
+						"weight": "Bolder",
+						"size":   "Medium",
+					},
+					{
+						"type":     "TextBlock",
+						"text":     summary,
+						"wrap":     true,
+						"fontType": "Monospace",
+					},
+				},
+			},
+		}},
+	}
+}
+
+// matches reports whether the composite carries every label and annotation of the route
+func (r Route) matches(d report.CompositeData) bool {
+	for k, v := range r.MatchLabels {
+		if d.Labels[k] != v {
+			return false
+		}
+	}
+	for k, v := range r.MatchAnnotations {
+		if d.Annotations[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies the set of failures in a composite so unchanged
+// failures are not notified again. Only stable fields are used; rendered
+// reasons contain ages and event counts that change on every run.
+func fingerprint(d report.CompositeData) string {
+	lines := []string{fmt.Sprintf("composite|%t|%s", d.Tree == nil, d.ErrorType)}
+
+	var walk func(node *report.ResourceStatus)
+	walk = func(node *report.ResourceStatus) {
+		if report.IsFailure(node.Status) {
+			status := node.Status
+			if strings.HasPrefix(status, "Error") {
+				// The fetch error message is summarized by its type
+				status = "Error"
+			}
+			var failing []string
+			for _, c := range node.Conditions {
+				if cond, ok := report.ParseCondition(c); ok && cond.Status != "True" {
+					failing = append(failing, cond.Type+"="+cond.Reason)
+				}
+			}
+			sort.Strings(failing)
+			lines = append(lines, strings.Join([]string{
+				node.Kind, node.Namespace, node.Name, status, strings.Join(failing, ","), node.ErrorType,
+			}, "|"))
+		}
+		for i := range node.Children {
+			walk(&node.Children[i])
+		}
+	}
+	if d.Tree != nil {
+		walk(d.Tree)
+	}
+
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadState reads the per-route fingerprints of composites already notified
+func loadState(path string) (map[string]map[string]string, error) {
+	state := make(map[string]map[string]string)
+	if path == "" {
+		return state, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification state: %v", err)
+	}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse notification state %s: %v", path, err)
+	}
+	return state, nil
+}
+
+func saveState(path string, state map[string]map[string]string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		return fmt.Errorf("failed to write notification state: %v", err)
+	}
+	return nil
+}
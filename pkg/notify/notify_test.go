@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+)
+
+// stub records the bodies posted to each path
+type stub struct {
+	mu     sync.Mutex
+	bodies map[string][]map[string]interface{}
+}
+
+func newStub(t *testing.T) (*stub, *httptest.Server) {
+	s := &stub{bodies: make(map[string][]map[string]interface{})}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("invalid JSON posted to %s: %v", r.URL.Path, err)
+		}
+		s.mu.Lock()
+		s.bodies[r.URL.Path] = append(s.bodies[r.URL.Path], body)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *stub) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies[path])
+}
+
+func unhealthyComposite(name string, labels, annotations map[string]string, transition time.Time) report.CompositeData {
+	return report.CompositeData{
+		Kind:        "XDatabase",
+		Name:        name,
+		Labels:      labels,
+		Annotations: annotations,
+		Tree: &report.ResourceStatus{
+			Kind:   "XDatabase",
+			Name:   name,
+			Status: "Unhealthy",
+			Synced: "False",
+			Ready:  "False",
+			Conditions: []string{
+				"Synced=False (ReconcileError): cannot compose resources",
+			},
+			Events:             []string{"[Warning] ComposeResources: cannot compose resources (x3)"},
+			LastTransitionTime: &transition,
+		},
+	}
+}
+
+func TestNotify(t *testing.T) {
+	s, srv := newStub(t)
+	config := &Config{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Routes: []Route{
+			{Name: "slack", Type: TypeSlack, URL: srv.URL + "/slack", MatchLabels: map[string]string{"team": "payments"}},
+			{Name: "teams", Type: TypeTeams, URL: srv.URL + "/teams", MatchAnnotations: map[string]string{"notify": "teams"}},
+			{Name: "webhook", Type: TypeWebhook, URL: srv.URL + "/webhook"},
+		},
+	}
+
+	now := time.Now()
+	data := []report.CompositeData{
+		unhealthyComposite("payments-db", map[string]string{"team": "payments"}, nil, now),
+		unhealthyComposite("orders-db", map[string]string{"team": "orders"}, map[string]string{"notify": "teams"}, now),
+		{Kind: "XDatabase", Name: "healthy-db", Tree: &report.ResourceStatus{Kind: "XDatabase", Name: "healthy-db", Status: "Available"}},
+	}
+
+	if err := NewNotifier(config, srv.Client()).Notify(context.Background(), data); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		contains string
+		check    func(body map[string]interface{}) bool
+	}{
+		{
+			path:     "/slack",
+			contains: "payments-db",
+			check:    func(body map[string]interface{}) bool { _, ok := body["text"].(string); return ok },
+		},
+		{
+			path:     "/teams",
+			contains: "orders-db",
+			check:    func(body map[string]interface{}) bool { return body["type"] == "message" && body["attachments"] != nil },
+		},
+		{
+			path:     "/webhook",
+			contains: "orders-db",
+			check: func(body map[string]interface{}) bool {
+				composites, ok := body["composites"].([]interface{})
+				return ok && len(composites) == 2 && body["route"] == "webhook"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := s.count(tt.path); got != 1 {
+				t.Fatalf("got %d requests, want 1", got)
+			}
+			body := s.bodies[tt.path][0]
+			if !tt.check(body) {
+				t.Errorf("unexpected payload: %v", body)
+			}
+			raw, _ := json.Marshal(body)
+			if !strings.Contains(string(raw), tt.contains) {
+				t.Errorf("payload does not mention %s: %s", tt.contains, raw)
+			}
+			if strings.Contains(string(raw), "healthy-db") {
+				t.Errorf("payload mentions a healthy composite: %s", raw)
+			}
+		})
+	}
+
+	// Slack only matches the payments team
+	if raw, _ := json.Marshal(s.bodies["/slack"][0]); strings.Contains(string(raw), "orders-db") {
+		t.Errorf("slack route received a composite it does not match: %s", raw)
+	}
+
+	// A second run with the same failures, only later and with more events,
+	// sends nothing, also from a new notifier reading the state file
+	later := []report.CompositeData{
+		unhealthyComposite("payments-db", map[string]string{"team": "payments"}, nil, now.Add(-time.Hour)),
+		unhealthyComposite("orders-db", map[string]string{"team": "orders"}, map[string]string{"notify": "teams"}, now.Add(-time.Hour)),
+	}
+	later[0].Tree.Events = []string{"[Warning] ComposeResources: cannot compose resources (x12)"}
+	if err := NewNotifier(config, srv.Client()).Notify(context.Background(), later); err != nil {
+		t.Fatalf("second Notify() error = %v", err)
+	}
+	for _, path := range []string{"/slack", "/teams", "/webhook"} {
+		if got := s.count(path); got != 1 {
+			t.Errorf("%s got %d requests after an identical run, want 1", path, got)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	now := time.Now()
+	base := unhealthyComposite("db", nil, nil, now)
+
+	tests := []struct {
+		name   string
+		modify func(d *report.CompositeData)
+		same   bool
+	}{
+		{
+			name:   "changing event counts",
+			modify: func(d *report.CompositeData) { d.Tree.Events = []string{"[Warning] ComposeResources: x (x99)"} },
+			same:   true,
+		},
+		{
+			name: "changing deletion age",
+			modify: func(d *report.CompositeData) {
+				d.Tree.Status = "Deleting"
+				ts := now.Add(-time.Hour)
+				d.Tree.DeletionTimestamp = &ts
+			},
+			same: false,
+		},
+		{
+			name:   "changing condition message",
+			modify: func(d *report.CompositeData) { d.Tree.Conditions = []string{"Synced=False (ReconcileError): other"} },
+			same:   true,
+		},
+		{
+			name:   "changing condition reason",
+			modify: func(d *report.CompositeData) { d.Tree.Conditions = []string{"Synced=False (CannotConnect): x"} },
+			same:   false,
+		},
+		{
+			name: "new failing child",
+			modify: func(d *report.CompositeData) {
+				d.Tree.Children = []report.ResourceStatus{{Kind: "Bucket", Name: "b", Status: "Error fetching: boom", ErrorType: report.ErrorForbidden}}
+			},
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := unhealthyComposite("db", nil, nil, now)
+			tt.modify(&d)
+			if got := fingerprint(d) == fingerprint(base); got != tt.same {
+				t.Errorf("fingerprints equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+
+	// Deletion age must not change the fingerprint between runs
+	a, b := unhealthyComposite("db", nil, nil, now), unhealthyComposite("db", nil, nil, now)
+	for i, d := range []*report.CompositeData{&a, &b} {
+		ts := now.Add(-time.Duration(i+1) * time.Minute)
+		d.Tree.Status = "Deleting"
+		d.Tree.DeletionTimestamp = &ts
+	}
+	if fingerprint(a) != fingerprint(b) {
+		t.Error("fingerprint changed with the age of a deletion")
+	}
+}
@@ -0,0 +1,162 @@
+package orphans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tree"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Orphan is a managed resource that no composite references or owns
+type Orphan struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Created    time.Time `json:"created"`
+	Reason     string    `json:"reason"`
+}
+
+// OwnerLookup reports whether the controlling owner of a managed resource in
+// namespace still exists. It returns an error when this cannot be determined.
+type OwnerLookup func(owner metav1.OwnerReference, namespace string) (bool, error)
+
+// Find returns the managed resources that are neither listed in the
+// spec.resourceRefs of any composite nor controlled by an existing owner.
+// Owners that are not among the composites are checked with ownerExists.
+// It also returns how many resources were skipped because their owner could
+// not be checked.
+func Find(composites, managed []unstructured.Unstructured, ownerExists OwnerLookup) ([]Orphan, int) {
+	referenced := make(map[string]bool)
+	compositeUIDs := make(map[types.UID]bool)
+	for _, xr := range composites {
+		compositeUIDs[xr.GetUID()] = true
+		for _, ref := range tree.ResourceRefs(&xr) {
+			referenced[key(ref.APIVersion, ref.Kind, ref.Namespace, ref.Name)] = true
+		}
+	}
+
+	var orphans []Orphan
+	unchecked := 0
+	for _, mr := range managed {
+		if referenced[key(mr.GetAPIVersion(), mr.GetKind(), mr.GetNamespace(), mr.GetName())] {
+			continue
+		}
+
+		var reason string
+		owned, failed := false, false
+		for _, owner := range mr.GetOwnerReferences() {
+			if owner.Controller == nil || !*owner.Controller {
+				continue
+			}
+			if compositeUIDs[owner.UID] {
+				owned = true
+				break
+			}
+			// The owner may be a composite that was not listed or another kind
+			exists, err := ownerExists(owner, mr.GetNamespace())
+			if err != nil {
+				failed = true
+				break
+			}
+			if exists {
+				owned = true
+				break
+			}
+			reason = fmt.Sprintf("controlling %s %s no longer exists", owner.Kind, owner.Name)
+		}
+		if failed {
+			unchecked++
+			continue
+		}
+		if owned {
+			continue
+		}
+		if reason == "" {
+			if composite := mr.GetLabels()[tree.LabelComposite]; composite != "" {
+				reason = fmt.Sprintf("composite %s no longer references it", composite)
+			} else {
+				reason = "not managed by any composite"
+			}
+		}
+
+		orphans = append(orphans, Orphan{
+			APIVersion: mr.GetAPIVersion(),
+			Kind:       mr.GetKind(),
+			Namespace:  mr.GetNamespace(),
+			Name:       mr.GetName(),
+			Created:    mr.GetCreationTimestamp().Time,
+			Reason:     reason,
+		})
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].Kind != orphans[j].Kind {
+			return orphans[i].Kind < orphans[j].Kind
+		}
+		if orphans[i].Namespace != orphans[j].Namespace {
+			return orphans[i].Namespace < orphans[j].Namespace
+		}
+		return orphans[i].Name < orphans[j].Name
+	})
+	return orphans, unchecked
+}
+
+// key identifies a resource by group, kind, namespace and name, ignoring the
+// version since references may use a different served version
+func key(apiVersion, kind, namespace, name string) string {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return strings.Join([]string{gv.Group, kind, namespace, name}, "/")
+}
+
+// WriteTable writes the orphans as an aligned table
+func WriteTable(w io.Writer, orphans []Orphan, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tAGE\tREASON")
+	for _, o := range orphans {
+		gv, _ := schema.ParseGroupVersion(o.APIVersion)
+		kind := o.Kind
+		if gv.Group != "" {
+			kind = o.Kind + "." + gv.Group
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", kind, o.Namespace, o.Name, age(now, o.Created), o.Reason)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the orphans as a JSON array
+func WriteJSON(w io.Writer, orphans []Orphan) error {
+	if orphans == nil {
+		orphans = []Orphan{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(orphans)
+}
+
+// age formats the time since created like kubectl does, e.g. "3d" or "5h"
+func age(now, created time.Time) string {
+	if created.IsZero() {
+		return "-"
+	}
+	d := now.Sub(created)
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
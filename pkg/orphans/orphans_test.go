@@ -0,0 +1,121 @@
+package orphans
+
+import (
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func composite(uid types.UID, namespace string, refs ...map[string]interface{}) unstructured.Unstructured {
+	var resourceRefs []interface{}
+	for _, ref := range refs {
+		resourceRefs = append(resourceRefs, ref)
+	}
+	xr := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.org/v1",
+		"kind":       "XDatabase",
+		"spec":       map[string]interface{}{"resourceRefs": resourceRefs},
+	}}
+	xr.SetName("xr")
+	xr.SetNamespace(namespace)
+	xr.SetUID(uid)
+	return xr
+}
+
+func managed(name string, owner *metav1.OwnerReference) unstructured.Unstructured {
+	mr := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rds.aws.upbound.io/v1beta1",
+		"kind":       "Instance",
+	}}
+	mr.SetName(name)
+	if owner != nil {
+		mr.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return mr
+}
+
+func controller(kind, name string, uid types.UID) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{APIVersion: "example.org/v1", Kind: kind, Name: name, UID: uid, Controller: &isController}
+}
+
+func TestFind(t *testing.T) {
+	xr := composite("xr-uid", "", map[string]interface{}{"apiVersion": "rds.aws.upbound.io/v1beta2", "kind": "Instance", "name": "referenced"})
+
+	tests := []struct {
+		name          string
+		managed       unstructured.Unstructured
+		ownerExists   OwnerLookup
+		wantReason    string
+		wantUnchecked int
+	}{
+		{
+			name:    "referenced in another version",
+			managed: managed("referenced", nil),
+		},
+		{
+			name:    "controlled by a listed composite",
+			managed: managed("owned", controller("XDatabase", "xr", "xr-uid")),
+		},
+		{
+			name:        "controlled by an existing owner that was not listed",
+			managed:     managed("owned", controller("Workspace", "ws", "ws-uid")),
+			ownerExists: func(metav1.OwnerReference, string) (bool, error) { return true, nil },
+		},
+		{
+			name:        "controlling owner is gone",
+			managed:     managed("orphan", controller("XDatabase", "gone", "gone-uid")),
+			ownerExists: func(metav1.OwnerReference, string) (bool, error) { return false, nil },
+			wantReason:  "controlling XDatabase gone no longer exists",
+		},
+		{
+			name:          "owner cannot be checked",
+			managed:       managed("unknown", controller("XDatabase", "other", "other-uid")),
+			ownerExists:   func(metav1.OwnerReference, string) (bool, error) { return false, errors.New("forbidden") },
+			wantUnchecked: 1,
+		},
+		{
+			name:       "no owner or reference",
+			managed:    managed("stray", nil),
+			wantReason: "not managed by any composite",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := tt.ownerExists
+			if lookup == nil {
+				lookup = func(owner metav1.OwnerReference, _ string) (bool, error) {
+					t.Errorf("unexpected owner lookup for %s", owner.Name)
+					return false, nil
+				}
+			}
+
+			found, unchecked := Find([]unstructured.Unstructured{xr}, []unstructured.Unstructured{tt.managed}, lookup)
+			if unchecked != tt.wantUnchecked {
+				t.Errorf("unchecked = %d, want %d", unchecked, tt.wantUnchecked)
+			}
+			switch {
+			case tt.wantReason == "" && len(found) > 0:
+				t.Errorf("got orphans %v, want none", found)
+			case tt.wantReason != "" && len(found) != 1:
+				t.Fatalf("got %d orphans, want 1", len(found))
+			case tt.wantReason != "" && found[0].Reason != tt.wantReason:
+				t.Errorf("reason = %q, want %q", found[0].Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestFindDefaultsReferenceNamespace(t *testing.T) {
+	xr := composite("xr-uid", "payments", map[string]interface{}{"apiVersion": "rds.aws.upbound.io/v1beta1", "kind": "Instance", "name": "db"})
+	mr := managed("db", nil)
+	mr.SetNamespace("payments")
+
+	found, _ := Find([]unstructured.Unstructured{xr}, []unstructured.Unstructured{mr}, nil)
+	if len(found) != 0 {
+		t.Errorf("resource referenced without a namespace by a namespaced composite reported as orphan: %v", found)
+	}
+}
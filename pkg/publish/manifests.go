@@ -0,0 +1,278 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Target is a namespaced object the report is written to
+type Target struct {
+	Namespace string
+	Name      string
+}
+
+// ManifestOptions controls which manifests are generated for running in-cluster
+type ManifestOptions struct {
+	// Name is used for the ServiceAccount, roles, bindings and CronJob
+	Name string
+	// Namespace is where the ServiceAccount and CronJob are installed
+	Namespace string
+	// ReadGroups are the API groups of the composites, claims and managed
+	// resources to diagnose, which are granted read access
+	ReadGroups []string
+	// ConfigMap and Report grant write access to these targets when set
+	ConfigMap *Target
+	Report    *Target
+	// CRD includes the DiagnosisReport CustomResourceDefinition
+	CRD bool
+	// CronJob includes a CronJob running the image on Schedule with Args
+	CronJob  bool
+	Image    string
+	Schedule string
+	Args     []string
+}
+
+// WriteManifests writes the requested manifests as a multi-document YAML stream
+func WriteManifests(w io.Writer, opts ManifestOptions) error {
+	var docs []map[string]interface{}
+	if opts.CRD {
+		docs = append(docs, reportCRD())
+	}
+	docs = append(docs, rbacManifests(opts)...)
+	if opts.CronJob {
+		docs = append(docs, cronJob(opts))
+	}
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		raw, err := yaml.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal manifest: %v", err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(raw)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func metadata(name, namespace string) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":   name,
+		"labels": map[string]interface{}{"app.kubernetes.io/name": "crossplane-diagnose"},
+	}
+	if namespace != "" {
+		m["namespace"] = namespace
+	}
+	return m
+}
+
+// crossplaneGroups are the Crossplane API groups read by the diagnosis, for
+// Usages, compositions and packages
+var crossplaneGroups = []string{"apiextensions.crossplane.io", "protection.crossplane.io", "pkg.crossplane.io"}
+
+// readRules grants read access to events, the Crossplane API groups and the
+// groups of the diagnosed resources. The core group is never granted as a
+// whole, so Secrets stay unreadable.
+func readRules(readGroups []string) []interface{} {
+	read := []interface{}{"get", "list"}
+	rules := []interface{}{
+		map[string]interface{}{
+			"apiGroups": []interface{}{"", "events.k8s.io"},
+			"resources": []interface{}{"events"},
+			"verbs":     read,
+		},
+	}
+
+	seen := make(map[string]bool)
+	var groups []interface{}
+	for _, group := range append(append([]string(nil), crossplaneGroups...), readGroups...) {
+		if group == "" || seen[group] {
+			continue
+		}
+		seen[group] = true
+		groups = append(groups, group)
+	}
+	return append(rules, map[string]interface{}{
+		"apiGroups": groups,
+		"resources": []interface{}{"*"},
+		"verbs":     read,
+	})
+}
+
+// rbacManifests grants read access to the resources the diagnosis reads, plus
+// write access to the report targets only
+func rbacManifests(opts ManifestOptions) []map[string]interface{} {
+	subject := map[string]interface{}{
+		"kind":      "ServiceAccount",
+		"name":      opts.Name,
+		"namespace": opts.Namespace,
+	}
+
+	docs := []map[string]interface{}{
+		{
+			"apiVersion": "v1",
+			"kind":       "ServiceAccount",
+			"metadata":   metadata(opts.Name, opts.Namespace),
+		},
+		{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   metadata(opts.Name, ""),
+			"rules":      readRules(opts.ReadGroups),
+		},
+		{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRoleBinding",
+			"metadata":   metadata(opts.Name, ""),
+			"roleRef": map[string]interface{}{
+				"apiGroup": "rbac.authorization.k8s.io",
+				"kind":     "ClusterRole",
+				"name":     opts.Name,
+			},
+			"subjects": []interface{}{subject},
+		},
+	}
+
+	// Group write rules per namespace so each namespace gets a single Role
+	rules := make(map[string][]interface{})
+	var namespaces []string
+	addRule := func(namespace, group, resource string) {
+		if _, ok := rules[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+		rules[namespace] = append(rules[namespace], map[string]interface{}{
+			"apiGroups": []interface{}{group},
+			"resources": []interface{}{resource},
+			"verbs":     []interface{}{"get", "create", "update"},
+		})
+	}
+	if opts.ConfigMap != nil {
+		addRule(opts.ConfigMap.Namespace, "", "configmaps")
+	}
+	if opts.Report != nil {
+		addRule(opts.Report.Namespace, ReportGroup, ReportResource)
+	}
+
+	writerName := opts.Name + "-writer"
+	for _, ns := range namespaces {
+		docs = append(docs,
+			map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "Role",
+				"metadata":   metadata(writerName, ns),
+				"rules":      rules[ns],
+			},
+			map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "RoleBinding",
+				"metadata":   metadata(writerName, ns),
+				"roleRef": map[string]interface{}{
+					"apiGroup": "rbac.authorization.k8s.io",
+					"kind":     "Role",
+					"name":     writerName,
+				},
+				"subjects": []interface{}{subject},
+			},
+		)
+	}
+	return docs
+}
+
+func cronJob(opts ManifestOptions) map[string]interface{} {
+	args := make([]interface{}, 0, len(opts.Args))
+	for _, a := range opts.Args {
+		args = append(args, a)
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "CronJob",
+		"metadata":   metadata(opts.Name, opts.Namespace),
+		"spec": map[string]interface{}{
+			"schedule":          opts.Schedule,
+			"concurrencyPolicy": "Forbid",
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"backoffLimit": 0,
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"serviceAccountName": opts.Name,
+							"restartPolicy":      "Never",
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name":  "crossplane-diagnose",
+									"image": opts.Image,
+									"args":  args,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// reportCRD defines the DiagnosisReport custom resource written by WriteReport
+func reportCRD() map[string]interface{} {
+	integer := map[string]interface{}{"type": "integer"}
+
+	return map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   metadata(ReportResource+"."+ReportGroup, ""),
+		"spec": map[string]interface{}{
+			"group": ReportGroup,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"kind":     ReportKind,
+				"listKind": ReportKind + "List",
+				"plural":   ReportResource,
+				"singular": "diagnosisreport",
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    ReportVersion,
+					"served":  true,
+					"storage": true,
+					"additionalPrinterColumns": []interface{}{
+						map[string]interface{}{"name": "Composites", "type": "integer", "jsonPath": ".spec.composites"},
+						map[string]interface{}{"name": "Unhealthy", "type": "integer", "jsonPath": ".spec.unhealthyComposites"},
+						map[string]interface{}{"name": "Generated", "type": "date", "jsonPath": ".spec.generatedAt"},
+					},
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"spec": map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"generatedAt":         map[string]interface{}{"type": "string", "format": "date-time"},
+										"composites":          integer,
+										"unhealthyComposites": integer,
+										"resources":           integer,
+										"unhealthyResources":  integer,
+										"summary":             map[string]interface{}{"type": "string"},
+										"report": map[string]interface{}{
+											"type": "array",
+											"items": map[string]interface{}{
+												"type":                                 "object",
+												"x-kubernetes-preserve-unknown-fields": true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Group, version and kind of the DiagnosisReport custom resource
+const (
+	ReportGroup    = "diagnose.crossplane-diagnose.io"
+	ReportVersion  = "v1alpha1"
+	ReportKind     = "DiagnosisReport"
+	ReportResource = "diagnosisreports"
+)
+
+// ReportGVR identifies the DiagnosisReport custom resource
+var ReportGVR = schema.GroupVersionResource{Group: ReportGroup, Version: ReportVersion, Resource: ReportResource}
+
+var configMapGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+// maxConfigMapData is how much data is written to a ConfigMap, leaving room
+// for its metadata below the 1 MiB object size limit
+const maxConfigMapData = 1000 * 1024
+
+// Writer stores the latest report in the cluster for other consumers
+type Writer struct {
+	client dynamic.Interface
+}
+
+// NewWriter creates a Writer
+func NewWriter(client dynamic.Interface) *Writer {
+	return &Writer{client: client}
+}
+
+// WriteConfigMap creates or updates a ConfigMap holding the summary and the JSON report
+func (w *Writer) WriteConfigMap(ctx context.Context, namespace, name string, generatedAt time.Time, data []report.CompositeData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	summary, _ := report.GetSummary(data)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"data": configMapData(generatedAt, summary, raw),
+	}}
+	obj.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "crossplane-diagnose"})
+
+	return w.apply(ctx, configMapGVR, obj)
+}
+
+// configMapData fits the summary and JSON report into a ConfigMap. When both
+// are too large the JSON report is left out, and the summary is truncated if
+// it does not fit on its own.
+func configMapData(generatedAt time.Time, summary string, raw []byte) map[string]interface{} {
+	data := map[string]interface{}{
+		"generatedAt": generatedAt.UTC().Format(time.RFC3339),
+	}
+	if len(summary)+len(raw) <= maxConfigMapData {
+		data["summary.txt"] = summary
+		data["report.json"] = string(raw)
+		return data
+	}
+
+	data["truncated"] = fmt.Sprintf("report.json (%d bytes) was left out to stay below the ConfigMap size limit", len(raw))
+	if len(summary) > maxConfigMapData {
+		summary = strings.ToValidUTF8(summary[:maxConfigMapData], "") + "\n... (truncated)\n"
+	}
+	data["summary.txt"] = summary
+	return data
+}
+
+// WriteReport creates or updates a DiagnosisReport custom resource
+func (w *Writer) WriteReport(ctx context.Context, namespace, name string, generatedAt time.Time, data []report.CompositeData) error {
+	// Round-trip through JSON so the report is stored as plain unstructured content
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var composites []interface{}
+	if err := json.Unmarshal(raw, &composites); err != nil {
+		return err
+	}
+	if composites == nil {
+		composites = []interface{}{}
+	}
+
+	stats := report.GetStats(data)
+	summary, _ := report.GetSummary(data)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": ReportGroup + "/" + ReportVersion,
+		"kind":       ReportKind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"generatedAt":         generatedAt.UTC().Format(time.RFC3339),
+			"composites":          int64(stats.Composites),
+			"unhealthyComposites": int64(stats.UnhealthyComposites),
+			"resources":           int64(stats.Resources),
+			"unhealthyResources":  int64(stats.UnhealthyResources),
+			"summary":             summary,
+			"report":              composites,
+		},
+	}}
+	obj.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "crossplane-diagnose"})
+
+	return w.apply(ctx, ReportGVR, obj)
+}
+
+// apply creates obj, or replaces the existing object's content if it already exists
+func (w *Writer) apply(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	client := w.client.Resource(gvr).Namespace(obj.GetNamespace())
+
+	existing, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := client.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if _, err := client.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
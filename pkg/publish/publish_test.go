@@ -0,0 +1,71 @@
+package publish
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigMapData(t *testing.T) {
+	tests := []struct {
+		name       string
+		summary    string
+		report     int
+		wantReport bool
+		wantCut    bool
+	}{
+		{name: "fits", summary: "ok", report: 1024, wantReport: true},
+		{name: "report too large", summary: "ok", report: maxConfigMapData, wantReport: false},
+		{name: "summary too large", summary: strings.Repeat("x", maxConfigMapData+1), report: 10, wantCut: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := configMapData(time.Now(), tt.summary, []byte(strings.Repeat("r", tt.report)))
+			_, hasReport := data["report.json"]
+			if hasReport != tt.wantReport {
+				t.Errorf("report.json present = %v, want %v", hasReport, tt.wantReport)
+			}
+			if _, truncated := data["truncated"]; truncated == tt.wantReport {
+				t.Errorf("truncated note present = %v, want %v", truncated, !tt.wantReport)
+			}
+			size := 0
+			for _, v := range data {
+				size += len(v.(string))
+			}
+			if size > maxConfigMapData+1024 {
+				t.Errorf("data is %d bytes, over the limit", size)
+			}
+			if got := strings.HasSuffix(data["summary.txt"].(string), "(truncated)\n"); got != tt.wantCut {
+				t.Errorf("summary truncated = %v, want %v", got, tt.wantCut)
+			}
+		})
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	rules := readRules([]string{"s3.aws.upbound.io", "", "apiextensions.crossplane.io"})
+	for _, r := range rules {
+		rule := r.(map[string]interface{})
+		groups := rule["apiGroups"].([]interface{})
+		resources := rule["resources"].([]interface{})
+		for _, g := range groups {
+			if g == "*" {
+				t.Errorf("rule grants every API group: %v", rule)
+			}
+			if g == "" && (len(resources) != 1 || resources[0] != "events") {
+				t.Errorf("core group rule grants more than events: %v", rule)
+			}
+		}
+	}
+
+	last := rules[len(rules)-1].(map[string]interface{})["apiGroups"].([]interface{})
+	want := []interface{}{"apiextensions.crossplane.io", "protection.crossplane.io", "pkg.crossplane.io", "s3.aws.upbound.io"}
+	if len(last) != len(want) {
+		t.Fatalf("groups = %v, want %v", last, want)
+	}
+	for i := range want {
+		if last[i] != want[i] {
+			t.Errorf("groups = %v, want %v", last, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package report
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlReport is the data passed to htmlTemplate
+type htmlReport struct {
+	Stats      Stats
+	Composites []htmlComposite
+}
+
+type htmlComposite struct {
+	Title     string
+	Error     string
+	Unhealthy bool
+	Tree      *ResourceStatus
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"badge":        statusBadgeClass,
+	"reason":       findReason,
+	"qualified":    qualifiedName,
+	"healthy":      IsHealthy,
+	"dash":         valueOrDash,
+	"hasUnhealthy": hasUnhealthy,
+	"childRef": func(node *ResourceStatus, i int) *ResourceStatus {
+		return &node.Children[i]
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Crossplane Diagnosis Report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.6em; }
+.summary { display: flex; gap: 1em; margin-bottom: 2em; }
+.summary div { border: 1px solid #d0d7de; border-radius: 6px; padding: 0.8em 1.2em; }
+.summary strong { display: block; font-size: 1.5em; }
+details { margin-left: 1.2em; }
+details.composite { margin: 0 0 1em 0; border: 1px solid #d0d7de; border-radius: 6px; padding: 0.6em; }
+summary { cursor: pointer; padding: 0.2em 0; }
+.leaf { margin-left: 2.4em; padding: 0.2em 0; }
+.badge { display: inline-block; border-radius: 1em; padding: 0 0.6em; font-size: 0.85em; font-weight: 600; color: #fff; }
+.badge.ok { background: #1a7f37; }
+.badge.bad { background: #cf222e; }
+.badge.warn { background: #9a6700; }
+.muted { color: #656d76; font-size: 0.85em; }
+.reason { color: #cf222e; font-family: monospace; margin: 0.2em 0 0.2em 1.2em; white-space: pre-wrap; }
+ol.timeline { border-left: 2px solid #d0d7de; list-style: none; margin: 0.3em 0 0.3em 1.2em; padding-left: 1em; }
+ol.timeline li { font-family: monospace; font-size: 0.85em; margin: 0.2em 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Crossplane Diagnosis Report</h1>
+<div class="summary">
+  <div><strong>{{.Stats.Composites}}</strong>Composites</div>
+  <div><strong>{{.Stats.UnhealthyComposites}}</strong>Unhealthy Composites</div>
+  <div><strong>{{.Stats.Resources}}</strong>Resources</div>
+  <div><strong>{{.Stats.UnhealthyResources}}</strong>Unhealthy Resources</div>
+</div>
+{{range .Composites}}
+<details class="composite"{{if .Unhealthy}} open{{end}}>
+  <summary><strong>{{.Title}}</strong>{{if .Error}} <span class="badge bad">Error</span>{{end}}</summary>
+  {{if .Error}}<div class="reason">{{.Error}}</div>{{else}}{{template "node" .Tree}}{{end}}
+</details>
+{{end}}
+</body>
+</html>
+{{define "node"}}
+{{if .Children}}<details{{if hasUnhealthy .}} open{{end}}>
+  <summary>{{template "line" .}}</summary>
+  {{template "detail" .}}
+  {{$node := .}}{{range $i, $child := .Children}}{{template "node" (childRef $node $i)}}{{end}}
+</details>{{else}}<div class="leaf">{{template "line" .}}{{template "detail" .}}</div>{{end}}
+{{end}}
+{{define "line"}}<span class="badge {{badge .Status}}">{{.Status}}</span> {{if .Highlighted}}<mark>{{.Kind}}/{{qualified .Namespace .Name}}</mark> <span class="muted">starting point</span>{{else}}{{.Kind}}/{{qualified .Namespace .Name}}{{end}} <span class="muted">Synced: {{dash .Synced}} · Ready: {{dash .Ready}}</span>{{end}}
+{{define "detail"}}{{if not (healthy .Status)}}
+  <div class="reason">{{reason .}}</div>
+  {{if .Events}}<ol class="timeline">{{range .Events}}<li>{{.}}</li>{{end}}</ol>{{end}}
+{{end}}{{end}}
+`))
+
+// GenerateHTML writes the report as a self-contained HTML page with collapsible subtrees
+func GenerateHTML(w io.Writer, data []CompositeData) error {
+	rep := htmlReport{Stats: GetStats(data)}
+	for _, d := range data {
+		rep.Composites = append(rep.Composites, htmlComposite{
+			Title:     CompositeName(d),
+			Error:     d.Error,
+			Unhealthy: d.Tree == nil || hasUnhealthy(d.Tree),
+			Tree:      d.Tree,
+		})
+	}
+	return htmlTemplate.Execute(w, rep)
+}
+
+func statusBadgeClass(status string) string {
+	switch statusLevel(status) {
+	case levelOK:
+		return "ok"
+	case levelError:
+		return "bad"
+	default:
+		return "warn"
+	}
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// GenerateJUnit writes the report as JUnit XML. Each composite is a test suite and
+// each resource in its tree is a test case; unhealthy resources are failures,
+// resources that could not be fetched are errors and paused or observe-only
+// resources are skipped.
+func GenerateJUnit(w io.Writer, data []CompositeData) error {
+	suites := junitTestSuites{Name: "crossplane-diagnose"}
+
+	for _, d := range data {
+		suiteName := CompositeName(d)
+		suite := junitTestSuite{Name: suiteName}
+
+		if d.Tree == nil {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      suiteName,
+				ClassName: suiteName,
+				Error: &junitFailure{
+					Message: d.Error,
+					Type:    "Error",
+					Text:    d.Error,
+				},
+			})
+		} else {
+			suite.TestCases = appendJUnitCases(suite.TestCases, d.Tree, suiteName, "")
+		}
+
+		for _, tc := range suite.TestCases {
+			suite.Tests++
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+			if tc.Error != nil {
+				suite.Errors++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func appendJUnitCases(cases []junitTestCase, node *ResourceStatus, suiteName, parentPath string) []junitTestCase {
+	path := fmt.Sprintf("%s/%s", node.Kind, qualifiedName(node.Namespace, node.Name))
+	if parentPath != "" {
+		path = parentPath + " > " + path
+	}
+
+	tc := junitTestCase{Name: path, ClassName: suiteName}
+	if IsDeliberate(node.Status) {
+		tc.Skipped = &junitSkipped{Message: findReason(node)}
+	} else if !IsHealthy(node.Status) {
+		failure := &junitFailure{
+			Message: findReason(node),
+			Type:    node.Status,
+			Text:    junitFailureText(node),
+		}
+		if strings.HasPrefix(node.Status, "Error") {
+			failure.Message = node.Status
+			failure.Type = "FetchError"
+			tc.Error = failure
+		} else {
+			tc.Failure = failure
+		}
+	}
+	cases = append(cases, tc)
+
+	for _, child := range node.Children {
+		cases = appendJUnitCases(cases, &child, suiteName, path)
+	}
+	return cases
+}
+
+// junitFailureText lists the node's conditions and events as the failure body
+func junitFailureText(node *ResourceStatus) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Status: %s\nSynced: %s\nReady: %s\n", node.Status, valueOrDash(node.Synced), valueOrDash(node.Ready))
+	if len(node.Conditions) > 0 {
+		fmt.Fprintln(&sb, "Conditions:")
+		for _, cond := range node.Conditions {
+			fmt.Fprintf(&sb, "  %s\n", cond)
+		}
+	}
+	if len(node.Events) > 0 {
+		fmt.Fprintln(&sb, "Events:")
+		for _, event := range node.Events {
+			fmt.Fprintf(&sb, "  %s\n", event)
+		}
+	}
+	return sb.String()
+}
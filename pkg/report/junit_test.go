@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+)
+
+func TestGenerateJUnit(t *testing.T) {
+	data := []CompositeData{
+		{Kind: "XDatabase", Name: "db", Tree: &ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available", Children: []ResourceStatus{
+			{Kind: "Instance", Name: "failing", Status: "Unhealthy", Conditions: []string{"Ready=False (ReconcileError): cannot connect"}},
+			{Kind: "Bucket", Name: "missing", Status: "Error fetching: not found"},
+			{Kind: "Role", Name: "paused", Status: "Paused"},
+			{Kind: "Policy", Name: "fine", Status: "Available"},
+		}}},
+		{Kind: "XNetwork", Name: "gone", Error: "failed to get XR gone"},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateJUnit(&buf, data); err != nil {
+		t.Fatalf("GenerateJUnit() error = %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if suites.Tests != 6 || suites.Failures != 1 || suites.Errors != 2 || suites.Skipped != 1 {
+		t.Errorf("totals tests=%d failures=%d errors=%d skipped=%d, want 6, 1, 2, 1",
+			suites.Tests, suites.Failures, suites.Errors, suites.Skipped)
+	}
+
+	cases := make(map[string]junitTestCase)
+	for _, suite := range suites.Suites {
+		for _, tc := range suite.TestCases {
+			cases[tc.Name] = tc
+		}
+	}
+
+	tests := []struct {
+		name    string
+		outcome string
+		message string
+	}{
+		{name: "XDatabase/db", outcome: "passed"},
+		{name: "XDatabase/db > Instance/failing", outcome: "failure", message: "Ready=False (ReconcileError): cannot connect"},
+		{name: "XDatabase/db > Bucket/missing", outcome: "error", message: "Error fetching: not found"},
+		{name: "XDatabase/db > Role/paused", outcome: "skipped"},
+		{name: "XDatabase/db > Policy/fine", outcome: "passed"},
+		{name: "XNetwork/gone", outcome: "error", message: "failed to get XR gone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, ok := cases[tt.name]
+			if !ok {
+				t.Fatalf("no test case named %q", tt.name)
+			}
+			outcome, message := "passed", ""
+			switch {
+			case tc.Failure != nil:
+				outcome, message = "failure", tc.Failure.Message
+			case tc.Error != nil:
+				outcome, message = "error", tc.Error.Message
+			case tc.Skipped != nil:
+				outcome = "skipped"
+			}
+			if outcome != tt.outcome {
+				t.Errorf("outcome = %s, want %s", outcome, tt.outcome)
+			}
+			if tt.message != "" && message != tt.message {
+				t.Errorf("message = %q, want %q", message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenerateMarkdown writes the report as Markdown suitable for incident tickets
+func GenerateMarkdown(w io.Writer, data []CompositeData) error {
+	var sb strings.Builder
+	stats := GetStats(data)
+
+	fmt.Fprintln(&sb, "# Crossplane Diagnosis Report")
+	fmt.Fprintln(&sb)
+	fmt.Fprintln(&sb, "| Composites | Unhealthy Composites | Resources | Unhealthy Resources |")
+	fmt.Fprintln(&sb, "| ---: | ---: | ---: | ---: |")
+	fmt.Fprintf(&sb, "| %d | %d | %d | %d |\n", stats.Composites, stats.UnhealthyComposites, stats.Resources, stats.UnhealthyResources)
+
+	for _, d := range data {
+		fmt.Fprintln(&sb)
+		if d.Tree == nil {
+			fmt.Fprintf(&sb, "## %s %s\n\n", statusEmoji("Error"), CompositeName(d))
+			fmt.Fprintf(&sb, "**Error:** %s\n", escapeMarkdown(d.Error))
+			continue
+		}
+
+		var unhealthy []*ResourceStatus
+		collectUnhealthyNodes(d.Tree, &unhealthy)
+		emoji := statusEmoji("Available")
+		if len(unhealthy) > 0 {
+			emoji = statusEmoji("Unhealthy")
+		}
+		fmt.Fprintf(&sb, "## %s %s\n\n", emoji, CompositeName(d))
+
+		writeMarkdownNode(&sb, d.Tree, 0)
+
+		if len(unhealthy) > 0 {
+			fmt.Fprintln(&sb)
+			fmt.Fprintln(&sb, "### Unhealthy Resources")
+			for _, node := range unhealthy {
+				fmt.Fprintln(&sb)
+				fmt.Fprintf(&sb, "#### %s/%s\n\n", node.Kind, qualifiedName(node.Namespace, node.Name))
+				fmt.Fprintf(&sb, "- **Status:** %s\n", escapeMarkdown(node.Status))
+				fmt.Fprintf(&sb, "- **Reason:** %s\n", escapeMarkdown(findReason(node)))
+				if len(node.Conditions) > 0 {
+					fmt.Fprintln(&sb, "- **Conditions:**")
+					for _, cond := range node.Conditions {
+						fmt.Fprintf(&sb, "  - %s\n", escapeMarkdown(cond))
+					}
+				}
+				if len(node.Events) > 0 {
+					fmt.Fprintln(&sb, "- **Events:**")
+					for _, event := range node.Events {
+						fmt.Fprintf(&sb, "  - %s\n", escapeMarkdown(event))
+					}
+				}
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeMarkdownNode(sb *strings.Builder, node *ResourceStatus, depth int) {
+	marker := ""
+	if node.Highlighted {
+		marker = " ⬅️ _starting point_"
+	}
+	fmt.Fprintf(sb, "%s- %s **%s/%s** — %s (Synced: %s, Ready: %s)%s\n",
+		strings.Repeat("  ", depth),
+		statusEmoji(node.Status),
+		node.Kind,
+		qualifiedName(node.Namespace, node.Name),
+		escapeMarkdown(node.Status),
+		valueOrDash(node.Synced),
+		valueOrDash(node.Ready),
+		marker,
+	)
+	for _, child := range node.Children {
+		writeMarkdownNode(sb, &child, depth+1)
+	}
+}
+
+// collectUnhealthyNodes appends every unhealthy node in the tree in depth-first order
+func collectUnhealthyNodes(node *ResourceStatus, out *[]*ResourceStatus) {
+	if !IsHealthy(node.Status) {
+		*out = append(*out, node)
+	}
+	for i := range node.Children {
+		collectUnhealthyNodes(&node.Children[i], out)
+	}
+}
+
+func statusEmoji(status string) string {
+	switch statusLevel(status) {
+	case levelOK:
+		return "✅"
+	case levelError:
+		return "❌"
+	default:
+		return "⚠️"
+	}
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escapeMarkdown flattens s onto one line and escapes characters that would
+// otherwise be interpreted as Markdown or HTML
+func escapeMarkdown(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		"`", "\\`",
+		"*", `\*`,
+		"_", `\_`,
+		"|", `\|`,
+		"<", "&lt;",
+		">", "&gt;",
+	)
+	return replacer.Replace(s)
+}
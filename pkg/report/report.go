@@ -5,29 +5,120 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // ResourceStatus holds detailed status for a specific resource
 type ResourceStatus struct {
-	Kind       string           `json:"kind"`
-	Name       string           `json:"name"`
-	Synced     string           `json:"synced"`
-	Ready      string           `json:"ready"`
-	Status     string           `json:"status"`
-	Events     []string         `json:"events,omitempty"`
-	Conditions []string         `json:"conditions,omitempty"`
-	Children   []ResourceStatus `json:"children,omitempty"`
+	APIVersion         string           `json:"api_version,omitempty"`
+	Kind               string           `json:"kind"`
+	Namespace          string           `json:"namespace,omitempty"`
+	Name               string           `json:"name"`
+	Synced             string           `json:"synced"`
+	Ready              string           `json:"ready"`
+	Status             string           `json:"status"`
+	LastTransitionTime *time.Time       `json:"last_transition_time,omitempty"`
+	Events             []string         `json:"events,omitempty"`
+	Conditions         []string         `json:"conditions,omitempty"`
+	Children           []ResourceStatus `json:"children,omitempty"`
+	Highlighted        bool             `json:"highlighted,omitempty"` // The resource the diagnosis was started from
+	DeletionTimestamp  *time.Time       `json:"deletion_timestamp,omitempty"`
+	Finalizers         []string         `json:"finalizers,omitempty"` // Finalizers still blocking deletion
+	DeletionPolicy     string           `json:"deletion_policy,omitempty"`
+	BlockedBy          []string         `json:"blocked_by,omitempty"`          // Usages preventing deletion
+	Paused             bool             `json:"paused,omitempty"`              // The crossplane.io/paused annotation is set
+	ManagementPolicies []string         `json:"management_policies,omitempty"` // Set when the resource is not fully managed
+	Generation         int64            `json:"generation,omitempty"`          // Set when the controller has not observed it yet
+	ObservedGeneration int64            `json:"observed_generation,omitempty"`
+	PendingSince       *time.Time       `json:"pending_since,omitempty"`    // When the controller started lagging behind
+	EventsForbidden    bool             `json:"events_forbidden,omitempty"` // Events could not be read for lack of access
+	Truncated          string           `json:"truncated,omitempty"`        // Why the children of the resource were not followed
+	ErrorType          string           `json:"error_type,omitempty"`       // Why the resource could not be fetched
 }
 
+// Error types of resources that could not be fetched
+const (
+	ErrorNotFound  = "NotFound"
+	ErrorForbidden = "Forbidden"
+	ErrorTransient = "Transient"
+	ErrorOther     = "Other"
+)
+
 // CompositeData holds information about a single composite resource and its trace
 type CompositeData struct {
-	Name        string          `json:"name"`
-	Kind        string          `json:"kind"`
-	TraceOutput string          `json:"trace_output,omitempty"` // Deprecated
-	Error       string          `json:"error,omitempty"`
-	Tree        *ResourceStatus `json:"tree,omitempty"`
+	Cluster     string            `json:"cluster,omitempty"` // Kubeconfig context, set when diagnosing several clusters
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Kind        string            `json:"kind"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"-"`                      // Used for routing only, too noisy for reports
+	TraceOutput string            `json:"trace_output,omitempty"` // Deprecated
+	Error       string            `json:"error,omitempty"`
+	ErrorType   string            `json:"error_type,omitempty"` // Why the tree could not be built
+	Tree        *ResourceStatus   `json:"tree,omitempty"`
+}
+
+// Condition is a status condition parsed from ResourceStatus.Conditions
+type Condition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+// conditionPattern parses conditions formatted as "Type=Status (Reason): Message"
+var conditionPattern = regexp.MustCompile(`(?s)^([^=]+)=(\S*) \(([^)]*)\): ?(.*)$`)
+
+// ParseCondition parses a condition string produced by the tree builder.
+// The boolean is false if s is not in the expected format.
+func ParseCondition(s string) (Condition, bool) {
+	m := conditionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Condition{}, false
+	}
+	return Condition{Type: m[1], Status: m[2], Reason: m[3], Message: m[4]}, true
+}
+
+// Stats holds aggregate counts for a report
+type Stats struct {
+	Composites          int
+	UnhealthyComposites int
+	Resources           int
+	UnhealthyResources  int
+}
+
+// GetStats counts composites and resources, and how many of them are unhealthy.
+// A composite whose tree could not be built counts as unhealthy.
+func GetStats(data []CompositeData) Stats {
+	var stats Stats
+	var walk func(*ResourceStatus) int
+	walk = func(node *ResourceStatus) int {
+		stats.Resources++
+		unhealthy := 0
+		if IsFailure(node.Status) {
+			stats.UnhealthyResources++
+			unhealthy++
+		}
+		for _, child := range node.Children {
+			unhealthy += walk(&child)
+		}
+		return unhealthy
+	}
+
+	for _, d := range data {
+		stats.Composites++
+		if d.Tree == nil {
+			stats.UnhealthyComposites++
+			continue
+		}
+		if walk(d.Tree) > 0 {
+			stats.UnhealthyComposites++
+		}
+	}
+	return stats
 }
 
 // GenerateJSON writes the report in JSON format
@@ -42,33 +133,40 @@ func GenerateCSV(w io.Writer, data []CompositeData) error {
 	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
+	withCluster := hasCluster(data)
+
 	// Header
 	header := []string{
 		"Root Name",
 		"Parent Kind",
 		"Parent Name",
 		"Kind",
+		"Namespace",
 		"Name",
 		"Status",
 		"Synced",
 		"Ready",
 		"Details",
 	}
+	if withCluster {
+		header = append([]string{"Cluster"}, header...)
+	}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
 	// Rows
 	for _, d := range data {
+		prefix := clusterColumn(d, withCluster)
 		if d.Tree != nil {
 			// Traverse tree and write rows
 			// Root has no parent
-			if err := writeNodeRecursive(writer, d.Tree, d.Name, "", ""); err != nil {
+			if err := writeNodeRecursive(writer, d.Tree, prefix, d.Name, "", ""); err != nil {
 				return err
 			}
 		} else {
 			// Fallback for error cases or empty trees
-			errRow := []string{d.Name, "", "", d.Kind, d.Name, "Error", "", "", d.Error}
+			errRow := append(prefix, d.Name, "", "", d.Kind, d.Namespace, d.Name, "Error", "", "", d.Error)
 			if err := writer.Write(errRow); err != nil {
 				return err
 			}
@@ -77,31 +175,32 @@ func GenerateCSV(w io.Writer, data []CompositeData) error {
 	return nil
 }
 
-func writeNodeRecursive(writer *csv.Writer, node *ResourceStatus, rootName, parentKind, parentName string) error {
+func writeNodeRecursive(writer *csv.Writer, node *ResourceStatus, prefix []string, rootName, parentKind, parentName string) error {
 	// Format Details
 	var details []string
 	details = append(details, node.Conditions...)
 	details = append(details, node.Events...)
 	detailsStr := strings.Join(details, "; ")
 
-	row := []string{
+	row := append(append([]string(nil), prefix...),
 		rootName,
 		parentKind,
 		parentName,
 		node.Kind,
+		node.Namespace,
 		node.Name,
 		node.Status,
 		node.Synced,
 		node.Ready,
 		detailsStr,
-	}
+	)
 
 	if err := writer.Write(row); err != nil {
 		return err
 	}
 
 	for _, child := range node.Children {
-		if err := writeNodeRecursive(writer, &child, rootName, node.Kind, node.Name); err != nil {
+		if err := writeNodeRecursive(writer, &child, prefix, rootName, node.Kind, node.Name); err != nil {
 			return err
 		}
 	}
@@ -114,77 +213,226 @@ func GenerateTable(w io.Writer, data []CompositeData) error {
 	writer := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
 	defer writer.Flush()
 
+	withCluster := hasCluster(data)
+
 	// Header
 	header := []string{
 		"ROOT NAME",
 		"PARENT KIND",
 		"PARENT NAME",
 		"KIND",
+		"NAMESPACE",
 		"NAME",
 		"STATUS",
 		"SYNCED",
 		"READY",
 		"DETAILS",
 	}
+	if withCluster {
+		header = append([]string{"CLUSTER"}, header...)
+	}
 	// Join with tabs
 	fmt.Fprintln(writer, strings.Join(header, "\t"))
 
 	// Rows
 	for _, d := range data {
+		prefix := clusterColumn(d, withCluster)
 		if d.Tree != nil {
-			if err := writeNodeRecursiveTable(writer, d.Tree, d.Name, "", ""); err != nil {
+			if err := writeNodeRecursiveTable(writer, d.Tree, prefix, d.Name, "", ""); err != nil {
 				return err
 			}
 		} else {
 			// Fallback
-			row := []string{d.Name, "", "", d.Kind, d.Name, "Error", "", "", d.Error}
+			row := append(prefix, d.Name, "", "", d.Kind, d.Namespace, d.Name, "Error", "", "", d.Error)
 			fmt.Fprintln(writer, strings.Join(row, "\t"))
 		}
 	}
 	return nil
 }
 
-func writeNodeRecursiveTable(writer *tabwriter.Writer, node *ResourceStatus, rootName, parentKind, parentName string) error {
+func writeNodeRecursiveTable(writer *tabwriter.Writer, node *ResourceStatus, prefix []string, rootName, parentKind, parentName string) error {
 	// Format Details
 	var details []string
 	details = append(details, node.Conditions...)
 	details = append(details, node.Events...)
 	detailsStr := strings.Join(details, "; ")
 
-	row := []string{
+	row := append(append([]string(nil), prefix...),
 		rootName,
 		parentKind,
 		parentName,
 		node.Kind,
+		node.Namespace,
 		node.Name,
 		node.Status,
 		node.Synced,
 		node.Ready,
 		detailsStr,
-	}
+	)
 
 	fmt.Fprintln(writer, strings.Join(row, "\t"))
 
 	for _, child := range node.Children {
-		if err := writeNodeRecursiveTable(writer, &child, rootName, node.Kind, node.Name); err != nil {
+		if err := writeNodeRecursiveTable(writer, &child, prefix, rootName, node.Kind, node.Name); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// hasCluster reports whether any composite is tagged with a cluster
+func hasCluster(data []CompositeData) bool {
+	for _, d := range data {
+		if d.Cluster != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterColumn returns the leading cluster cell of a row, if the report has one
+func clusterColumn(d CompositeData, withCluster bool) []string {
+	if !withCluster {
+		return nil
+	}
+	return []string{d.Cluster}
+}
+
+// IsHealthy reports whether a node status counts as healthy
+func IsHealthy(status string) bool {
+	return status == "Available" || status == "Synced"
+}
+
+// IsDeliberate reports whether a node is not reconciled on purpose, because it
+// is paused or only observed, rather than because something failed
+func IsDeliberate(status string) bool {
+	return status == "Paused" || status == "ObserveOnly"
+}
+
+// IsFailure reports whether a node status is a genuine failure. A resource
+// whose controller is still catching up with its spec is not one.
+func IsFailure(status string) bool {
+	return !IsHealthy(status) && !IsDeliberate(status) && status != "Progressing"
+}
+
+// Presentation levels returned by statusLevel
+const (
+	levelOK      = "ok"
+	levelError   = "error"
+	levelWarning = "warning"
+)
+
+// statusLevel groups a node status for presentation in the rendered formats
+func statusLevel(status string) string {
+	switch {
+	case IsHealthy(status):
+		return levelOK
+	case status == "Unhealthy", strings.HasPrefix(status, "Error"):
+		return levelError
+	default:
+		return levelWarning
+	}
+}
+
+// hasUnhealthy reports whether node or any of its descendants is unhealthy
+func hasUnhealthy(node *ResourceStatus) bool {
+	if !IsHealthy(node.Status) {
+		return true
+	}
+	for i := range node.Children {
+		if hasUnhealthy(&node.Children[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// PruneHealthy drops healthy subtrees from every composite, keeping the ancestor
+// path to each unhealthy node. Composites without any unhealthy node are removed,
+// while composites that failed to build are kept.
+func PruneHealthy(data []CompositeData) []CompositeData {
+	var pruned []CompositeData
+	for _, d := range data {
+		if d.Tree == nil {
+			pruned = append(pruned, d)
+			continue
+		}
+		if tree, ok := pruneNode(d.Tree); ok {
+			d.Tree = tree
+			pruned = append(pruned, d)
+		}
+	}
+	return pruned
+}
+
+// pruneNode returns a copy of node restricted to unhealthy nodes and their ancestors.
+// The boolean is false when the whole subtree is healthy.
+func pruneNode(node *ResourceStatus) (*ResourceStatus, bool) {
+	var children []ResourceStatus
+	for _, child := range node.Children {
+		if prunedChild, ok := pruneNode(&child); ok {
+			children = append(children, *prunedChild)
+		}
+	}
+
+	if IsHealthy(node.Status) && len(children) == 0 {
+		return nil, false
+	}
+
+	copied := *node
+	copied.Children = children
+	return &copied, true
+}
+
 // GetSummary returns a summary of the diagnosis and a boolean indicating if there are failures
 func GetSummary(data []CompositeData) (string, bool) {
+	var sb strings.Builder
+	fmt.Fprintln(&sb, "\n--- Summary ---")
+	hasFailures := writeSummary(&sb, data)
+	return sb.String(), hasFailures
+}
+
+// GetClusterSummary returns the summary grouped by cluster, in the order of
+// clusters, including clusters whose diagnosis failed as given by clusterErrors
+func GetClusterSummary(data []CompositeData, clusters []string, clusterErrors map[string]string) (string, bool) {
 	var sb strings.Builder
 	hasFailures := false
 
 	fmt.Fprintln(&sb, "\n--- Summary ---")
+	for _, cluster := range clusters {
+		if msg, failed := clusterErrors[cluster]; failed {
+			hasFailures = true
+			fmt.Fprintf(&sb, "=== Cluster %s: ❌ diagnosis failed ===\n%s\n\n", cluster, msg)
+			continue
+		}
+
+		var clusterData []CompositeData
+		for _, d := range data {
+			if d.Cluster == cluster {
+				clusterData = append(clusterData, d)
+			}
+		}
+		stats := GetStats(clusterData)
+		fmt.Fprintf(&sb, "=== Cluster %s: %d composites, %d unhealthy ===\n", cluster, stats.Composites, stats.UnhealthyComposites)
+		if writeSummary(&sb, clusterData) {
+			hasFailures = true
+		} else {
+			fmt.Fprintln(&sb, "")
+		}
+	}
+	return sb.String(), hasFailures
+}
+
+// writeSummary writes the unhealthy resources of every composite and reports
+// whether any were found
+func writeSummary(sb *strings.Builder, data []CompositeData) bool {
+	hasFailures := false
 
 	// Helper to collect unhealthy resources
 	var collectUnhealthy func(*ResourceStatus) []ResourceStatus
 	collectUnhealthy = func(node *ResourceStatus) []ResourceStatus {
 		var unhealthy []ResourceStatus
-		if node.Status != "Available" && node.Status != "Synced" {
+		if !IsHealthy(node.Status) {
 			unhealthy = append(unhealthy, *node)
 		}
 		for _, child := range node.Children {
@@ -193,40 +441,203 @@ func GetSummary(data []CompositeData) (string, bool) {
 		return unhealthy
 	}
 
-	failuresFound := false
+	deliberate := 0
 	for _, d := range data {
 		if d.Tree != nil {
 			unhealthy := collectUnhealthy(d.Tree)
 			if len(unhealthy) > 0 {
-				failuresFound = true
-				hasFailures = true
-				fmt.Fprintf(&sb, "❌ Top Parent: %s/%s\n", d.Kind, d.Name)
+				// Paused, observe-only and progressing resources are listed but are not failures
+				marker := "⏸️"
 				for _, res := range unhealthy {
-					// Find the most relevant reason
-					reason := "Unknown reason"
-					if len(res.Conditions) > 0 {
-						for _, cond := range res.Conditions {
-							if strings.Contains(cond, "False") || strings.Contains(cond, "Unknown") {
-								reason = cond
-								break
-							}
-						}
-						if reason == "Unknown reason" {
-							reason = res.Conditions[0]
-						}
-					} else if len(res.Events) > 0 {
-						reason = res.Events[0]
+					if IsFailure(res.Status) {
+						marker = "❌"
+						hasFailures = true
+					} else {
+						deliberate++
 					}
-					fmt.Fprintf(&sb, "  - Child %s/%s: %s\n    Reason: %s\n", res.Kind, res.Name, res.Status, reason)
 				}
-				fmt.Fprintln(&sb, "") // Empty line between parents
+				fmt.Fprintf(sb, "%s Top Parent: %s\n", marker, CompositeName(d))
+				for _, res := range unhealthy {
+					reason := findReason(&res)
+					fmt.Fprintf(sb, "  - Child %s/%s: %s\n    Reason: %s\n", res.Kind, qualifiedName(res.Namespace, res.Name), res.Status, reason)
+					if len(res.ManagementPolicies) > 0 {
+						fmt.Fprintf(sb, "    Management policies: %s\n", strings.Join(res.ManagementPolicies, ", "))
+					}
+				}
+				fmt.Fprintln(sb, "") // Empty line between parents
 			}
 		}
 	}
 
-	if !failuresFound {
-		fmt.Fprintln(&sb, "✅ All resources are healthy!")
+	if failed := countFetchFailures(data); len(failed) > 0 {
+		hasFailures = true
+		total := 0
+		var types []string
+		for _, errorType := range []string{ErrorNotFound, ErrorForbidden, ErrorTransient, ErrorOther} {
+			if failed[errorType] > 0 {
+				total += failed[errorType]
+				types = append(types, fmt.Sprintf("%d %s", failed[errorType], errorType))
+			}
+		}
+		fmt.Fprintf(sb, "⚠️ %d resources could not be fetched, the report is partial (%s).\n", total, strings.Join(types, ", "))
 	}
 
-	return sb.String(), hasFailures
+	if denied := countEventsForbidden(data); denied > 0 {
+		fmt.Fprintf(sb, "⚠️ Events could not be read for %d resources: access to events was denied.\n", denied)
+	}
+
+	if !hasFailures {
+		if deliberate > 0 {
+			fmt.Fprintf(sb, "✅ No failures, %d resources are paused, observe-only or progressing.\n", deliberate)
+		} else {
+			fmt.Fprintln(sb, "✅ All resources are healthy!")
+		}
+	}
+
+	return hasFailures
+}
+
+// countFetchFailures counts the composites and resources that could not be
+// fetched by error type
+func countFetchFailures(data []CompositeData) map[string]int {
+	counts := make(map[string]int)
+	var walk func(*ResourceStatus)
+	walk = func(node *ResourceStatus) {
+		if strings.HasPrefix(node.Status, "Error") {
+			counts[errorTypeOrOther(node.ErrorType)]++
+		}
+		for i := range node.Children {
+			walk(&node.Children[i])
+		}
+	}
+	for _, d := range data {
+		if d.Tree == nil {
+			counts[errorTypeOrOther(d.ErrorType)]++
+			continue
+		}
+		walk(d.Tree)
+	}
+	return counts
+}
+
+func errorTypeOrOther(errorType string) string {
+	if errorType == "" {
+		return ErrorOther
+	}
+	return errorType
+}
+
+// countEventsForbidden counts the resources whose events could not be read
+func countEventsForbidden(data []CompositeData) int {
+	count := 0
+	var walk func(*ResourceStatus)
+	walk = func(node *ResourceStatus) {
+		if node.EventsForbidden {
+			count++
+		}
+		for i := range node.Children {
+			walk(&node.Children[i])
+		}
+	}
+	for _, d := range data {
+		if d.Tree != nil {
+			walk(d.Tree)
+		}
+	}
+	return count
+}
+
+// findReason returns the most relevant explanation for a node's status
+func findReason(node *ResourceStatus) string {
+	switch node.Status {
+	case "Deleting":
+		return DeletionReason(node, time.Now())
+	case "Paused":
+		return "the crossplane.io/paused annotation is set, the resource is not reconciled"
+	case "ObserveOnly":
+		return fmt.Sprintf("managementPolicies are %s, changes are not applied", strings.Join(node.ManagementPolicies, ", "))
+	case "Stale", "Progressing":
+		return GenerationReason(node, time.Now())
+	case "Cycle":
+		return node.Truncated
+	}
+	if len(node.Conditions) > 0 {
+		for _, cond := range node.Conditions {
+			if strings.Contains(cond, "False") || strings.Contains(cond, "Unknown") {
+				return cond
+			}
+		}
+		return node.Conditions[0]
+	}
+	// Events are sorted most recent first; Normal events rarely explain a failure
+	for _, event := range node.Events {
+		if strings.HasPrefix(event, "[Warning]") {
+			return event
+		}
+	}
+	return "Unknown reason"
+}
+
+// CompositeName identifies a composite as Kind/[namespace/]name, prefixed with
+// its cluster when it has one
+func CompositeName(d CompositeData) string {
+	name := d.Kind + "/" + qualifiedName(d.Namespace, d.Name)
+	if d.Cluster != "" {
+		return d.Cluster + ": " + name
+	}
+	return name
+}
+
+// ResourcePath extends parentPath with a resource as
+// "parent > Kind/[namespace/]name", identifying it across reports
+func ResourcePath(parentPath, kind, namespace, name string) string {
+	path := kind + "/" + qualifiedName(namespace, name)
+	if parentPath != "" {
+		return parentPath + " > " + path
+	}
+	return path
+}
+
+// CompositePath returns the path of a composite's root, under its cluster when
+// it has one. It is the same whether or not the composite's tree was built.
+func CompositePath(d CompositeData) string {
+	return ResourcePath(d.Cluster, d.Kind, d.Namespace, d.Name)
+}
+
+// DeletionReason explains what a resource being deleted is waiting on
+func DeletionReason(node *ResourceStatus, now time.Time) string {
+	var parts []string
+	if node.DeletionTimestamp != nil {
+		parts = append(parts, fmt.Sprintf("deleting for %s", now.Sub(*node.DeletionTimestamp).Round(time.Second)))
+	}
+	if len(node.Finalizers) > 0 {
+		parts = append(parts, "waiting on finalizers "+strings.Join(node.Finalizers, ", "))
+	}
+	if len(node.BlockedBy) > 0 {
+		parts = append(parts, "blocked by "+strings.Join(node.BlockedBy, ", "))
+	}
+	if node.DeletionPolicy != "" {
+		parts = append(parts, "deletionPolicy "+node.DeletionPolicy)
+	}
+	if len(node.ManagementPolicies) > 0 {
+		parts = append(parts, "managementPolicies "+strings.Join(node.ManagementPolicies, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// GenerationReason explains how far a controller lags behind a resource's spec
+func GenerationReason(node *ResourceStatus, now time.Time) string {
+	reason := fmt.Sprintf("generation %d not observed yet (observedGeneration %d)", node.Generation, node.ObservedGeneration)
+	if node.PendingSince != nil {
+		reason = fmt.Sprintf("generation %d not observed for %s (observedGeneration %d)", node.Generation, now.Sub(*node.PendingSince).Round(time.Second), node.ObservedGeneration)
+	}
+	return reason
+}
+
+// qualifiedName prefixes name with its namespace for namespaced resources
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
 }
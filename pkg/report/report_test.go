@@ -0,0 +1,86 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+// shape renders a tree as kind/name[children] for comparison
+func shape(node *ResourceStatus) string {
+	s := node.Kind + "/" + node.Name
+	if len(node.Children) > 0 {
+		var children []string
+		for i := range node.Children {
+			children = append(children, shape(&node.Children[i]))
+		}
+		s += "[" + strings.Join(children, " ") + "]"
+	}
+	return s
+}
+
+func TestPruneHealthy(t *testing.T) {
+	tests := []struct {
+		name string
+		data []CompositeData
+		want []string
+	}{
+		{
+			name: "healthy tree is dropped",
+			data: []CompositeData{{Name: "a", Tree: &ResourceStatus{Kind: "XR", Name: "a", Status: "Available", Children: []ResourceStatus{
+				{Kind: "Bucket", Name: "b", Status: "Available"},
+			}}}},
+		},
+		{
+			name: "unhealthy leaf keeps its ancestors only",
+			data: []CompositeData{{Name: "a", Tree: &ResourceStatus{Kind: "XR", Name: "a", Status: "Available", Children: []ResourceStatus{
+				{Kind: "Bucket", Name: "ok", Status: "Available"},
+				{Kind: "XNet", Name: "net", Status: "Available", Children: []ResourceStatus{
+					{Kind: "Subnet", Name: "bad", Status: "Unhealthy"},
+					{Kind: "Subnet", Name: "good", Status: "Available"},
+				}},
+			}}}},
+			want: []string{"XR/a[XNet/net[Subnet/bad]]"},
+		},
+		{
+			name: "unhealthy root keeps no healthy children",
+			data: []CompositeData{{Name: "a", Tree: &ResourceStatus{Kind: "XR", Name: "a", Status: "Unhealthy", Children: []ResourceStatus{
+				{Kind: "Bucket", Name: "b", Status: "Available"},
+			}}}},
+			want: []string{"XR/a"},
+		},
+		{
+			name: "composites that could not be fetched are kept",
+			data: []CompositeData{{Kind: "XR", Name: "gone", Error: "not found"}},
+			want: []string{"<nil>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := shapes(tt.data)
+			var got []string
+			for _, d := range PruneHealthy(tt.data) {
+				if d.Tree == nil {
+					got = append(got, "<nil>")
+					continue
+				}
+				got = append(got, shape(d.Tree))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("PruneHealthy() = %v, want %v", got, tt.want)
+			}
+			if after := shapes(tt.data); after != original {
+				t.Errorf("PruneHealthy() modified its input: %s, was %s", after, original)
+			}
+		})
+	}
+}
+
+func shapes(data []CompositeData) string {
+	var s []string
+	for _, d := range data {
+		if d.Tree != nil {
+			s = append(s, shape(d.Tree))
+		}
+	}
+	return strings.Join(s, ",")
+}
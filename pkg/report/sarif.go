@@ -0,0 +1,171 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "crossplane-diagnose"
+	toolURI      = "https://github.com/vinish86/crossplane-diagnose"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// eventPattern parses events formatted as "[Type] Reason: Message"
+var eventPattern = regexp.MustCompile(`^\[[^\]]*\] ([^:]+):`)
+
+// GenerateSARIF writes the report as a SARIF 2.1.0 log. Every unhealthy resource
+// becomes a result whose rule ID is the reason of its most relevant condition.
+func GenerateSARIF(w io.Writer, data []CompositeData) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	ruleIndex := make(map[string]int)
+
+	addResult := func(ruleID, level, message, name, path string) {
+		idx, ok := ruleIndex[ruleID]
+		if !ok {
+			idx = len(run.Tool.Driver.Rules)
+			ruleIndex[ruleID] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               ruleID,
+				Name:             ruleID,
+				ShortDescription: sarifMessage{Text: fmt.Sprintf("Crossplane resource reported %s", ruleID)},
+			})
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleID,
+			RuleIndex: idx,
+			Level:     level,
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+				Name:               name,
+				FullyQualifiedName: path,
+				Kind:               "resource",
+			}}}},
+		})
+	}
+
+	var walk func(node *ResourceStatus, parentPath string)
+	walk = func(node *ResourceStatus, parentPath string) {
+		name := fmt.Sprintf("%s/%s", node.Kind, qualifiedName(node.Namespace, node.Name))
+		path := ResourcePath(parentPath, node.Kind, node.Namespace, node.Name)
+
+		if !IsHealthy(node.Status) {
+			reason := findReason(node)
+			message := fmt.Sprintf("%s is %s: %s", name, node.Status, reason)
+			if strings.HasPrefix(node.Status, "Error") {
+				message = fmt.Sprintf("%s: %s", name, node.Status)
+			}
+			addResult(sarifRuleID(node), sarifLevel(node.Status), message, name, path)
+		}
+
+		for i := range node.Children {
+			walk(&node.Children[i], path)
+		}
+	}
+
+	for _, d := range data {
+		if d.Tree == nil {
+			name := fmt.Sprintf("%s/%s", d.Kind, qualifiedName(d.Namespace, d.Name))
+			addResult("FetchError", "error", fmt.Sprintf("%s: %s", CompositeName(d), d.Error), name, CompositePath(d))
+			continue
+		}
+		walk(d.Tree, d.Cluster)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// sarifRuleID derives a rule ID such as "ReconcileError" from the node's
+// most relevant condition or event reason
+func sarifRuleID(node *ResourceStatus) string {
+	if strings.HasPrefix(node.Status, "Error") {
+		return "FetchError"
+	}
+
+	reason := findReason(node)
+	if cond, ok := ParseCondition(reason); ok && cond.Reason != "" {
+		return cond.Reason
+	}
+	if m := eventPattern.FindStringSubmatch(reason); m != nil && m[1] != "" {
+		return m[1]
+	}
+	return node.Status
+}
+
+// sarifLevel maps a node status onto a SARIF result level. Every status that
+// counts as a failure, including Stale, Deleting and Cycle, is an error so that
+// code-scanning results agree with the exit code. Paused, observe-only and
+// Progressing resources are reported as notes.
+func sarifLevel(status string) string {
+	if IsFailure(status) {
+		return "error"
+	}
+	return "note"
+}
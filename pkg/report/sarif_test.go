@@ -0,0 +1,99 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestGenerateSARIF(t *testing.T) {
+	data := []CompositeData{
+		{Kind: "XDatabase", Name: "db", Cluster: "prod", Tree: &ResourceStatus{Kind: "XDatabase", Name: "db", Status: "Available", Children: []ResourceStatus{
+			{Kind: "Instance", Name: "a", Status: "Unhealthy", Conditions: []string{"Ready=False (ReconcileError): cannot connect"}},
+			{Kind: "Instance", Name: "b", Status: "Unhealthy", Conditions: []string{"Synced=False (ReconcileError): throttled"}},
+			{Kind: "Bucket", Name: "c", Status: "Unhealthy", Events: []string{"[Warning] CannotCreateExternalResource: denied (x3)"}},
+			{Kind: "Role", Name: "d", Status: "ObserveOnly", ManagementPolicies: []string{"Observe"}},
+			{Kind: "Policy", Name: "e", Status: "Error fetching: forbidden"},
+			{Kind: "Policy", Name: "fine", Status: "Available"},
+		}}},
+		{Kind: "XNetwork", Name: "gone", Cluster: "prod", Error: "not found"},
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateSARIF(&buf, data); err != nil {
+		t.Fatalf("GenerateSARIF() error = %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != sarifVersion || len(log.Runs) != 1 {
+		t.Fatalf("version %s with %d runs, want %s with 1 run", log.Version, len(log.Runs), sarifVersion)
+	}
+	run := log.Runs[0]
+	if want := "https://github.com/vinish86/crossplane-diagnose"; run.Tool.Driver.InformationURI != want {
+		t.Errorf("informationUri = %s, want %s", run.Tool.Driver.InformationURI, want)
+	}
+
+	tests := []struct {
+		location string
+		ruleID   string
+		level    string
+	}{
+		{location: "prod > XDatabase/db > Instance/a", ruleID: "ReconcileError", level: "error"},
+		{location: "prod > XDatabase/db > Instance/b", ruleID: "ReconcileError", level: "error"},
+		{location: "prod > XDatabase/db > Bucket/c", ruleID: "CannotCreateExternalResource", level: "error"},
+		{location: "prod > XDatabase/db > Role/d", ruleID: "ObserveOnly", level: "note"},
+		{location: "prod > XDatabase/db > Policy/e", ruleID: "FetchError", level: "error"},
+		{location: "prod > XNetwork/gone", ruleID: "FetchError", level: "error"},
+	}
+	if len(run.Results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(run.Results), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			result := run.Results[i]
+			if got := result.Locations[0].LogicalLocations[0].FullyQualifiedName; got != tt.location {
+				t.Errorf("location = %s, want %s", got, tt.location)
+			}
+			if result.RuleID != tt.ruleID || result.Level != tt.level {
+				t.Errorf("rule %s level %s, want %s %s", result.RuleID, result.Level, tt.ruleID, tt.level)
+			}
+			if rule := run.Tool.Driver.Rules[result.RuleIndex]; rule.ID != result.RuleID {
+				t.Errorf("ruleIndex %d points at rule %s, want %s", result.RuleIndex, rule.ID, result.RuleID)
+			}
+		})
+	}
+
+	// Results sharing a reason share a rule
+	if len(run.Tool.Driver.Rules) != 4 {
+		t.Errorf("got %d rules, want 4", len(run.Tool.Driver.Rules))
+	}
+}
+
+func TestSARIFLevel(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{status: "Unhealthy", want: "error"},
+		{status: "Error fetching: forbidden", want: "error"},
+		{status: "Stale", want: "error"},
+		{status: "Deleting", want: "error"},
+		{status: "Cycle", want: "error"},
+		{status: "Progressing", want: "note"},
+		{status: "Paused", want: "note"},
+		{status: "ObserveOnly", want: "note"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := sarifLevel(tt.status); got != tt.want {
+				t.Errorf("sarifLevel(%q) = %s, want %s", tt.status, got, tt.want)
+			}
+			if IsFailure(tt.status) != (tt.want == "error") {
+				t.Errorf("IsFailure(%q) = %v, disagrees with level %s", tt.status, IsFailure(tt.status), tt.want)
+			}
+		})
+	}
+}
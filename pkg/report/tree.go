@@ -0,0 +1,196 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape codes used to color the tree output
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBold   = "\033[1m"
+)
+
+// defaultMaxReasonWidth is used when TreeOptions.MaxReasonWidth is not set
+const defaultMaxReasonWidth = 80
+
+// TreeOptions controls how GenerateTree renders resources
+type TreeOptions struct {
+	// Color enables ANSI colors for status columns
+	Color bool
+	// ASCII draws branches with plain ASCII instead of Unicode box characters
+	ASCII bool
+	// MaxReasonWidth truncates the reason shown next to unhealthy resources
+	MaxReasonWidth int
+}
+
+// treeCell is a single column value together with its color
+type treeCell struct {
+	text  string
+	color string
+}
+
+// treeGlyphs holds the branch prefixes for a tree style
+type treeGlyphs struct {
+	branch, last, pipe, space string
+}
+
+var (
+	unicodeGlyphs = treeGlyphs{branch: "├─ ", last: "└─ ", pipe: "│  ", space: "   "}
+	asciiGlyphs   = treeGlyphs{branch: "|- ", last: "`- ", pipe: "|  ", space: "   "}
+)
+
+// GenerateTree writes each composite as an indented tree, similar to `crossplane beta trace`
+func GenerateTree(w io.Writer, data []CompositeData, opts TreeOptions) error {
+	if opts.MaxReasonWidth <= 0 {
+		opts.MaxReasonWidth = defaultMaxReasonWidth
+	}
+	glyphs := unicodeGlyphs
+	if opts.ASCII {
+		glyphs = asciiGlyphs
+	}
+
+	header := []treeCell{{text: "NAME"}, {text: "SYNCED"}, {text: "READY"}, {text: "STATUS"}}
+	var blocks [][][]treeCell
+	for _, d := range data {
+		var rows [][]treeCell
+		if d.Tree != nil {
+			rows = appendTreeRows(rows, d.Tree, "", "", glyphs, opts)
+		} else {
+			rows = append(rows, []treeCell{
+				{text: CompositeName(d)},
+				{text: "-"},
+				{text: "-"},
+				{text: truncate("Error: "+d.Error, opts.MaxReasonWidth), color: colorRed},
+			})
+		}
+		blocks = append(blocks, rows)
+	}
+
+	// Align columns across all composites
+	widths := make([]int, len(header))
+	measure := func(row []treeCell) {
+		for i, c := range row {
+			if n := utf8.RuneCountInString(c.text); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	measure(header)
+	for _, rows := range blocks {
+		for _, row := range rows {
+			measure(row)
+		}
+	}
+
+	if err := writeTreeRow(w, header, widths, opts.Color); err != nil {
+		return err
+	}
+	for i, rows := range blocks {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		for _, row := range rows {
+			if err := writeTreeRow(w, row, widths, opts.Color); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func appendTreeRows(rows [][]treeCell, node *ResourceStatus, prefix, childPrefix string, glyphs treeGlyphs, opts TreeOptions) [][]treeCell {
+	status := node.Status
+	if !IsHealthy(node.Status) && !strings.HasPrefix(node.Status, "Error") {
+		status = fmt.Sprintf("%s: %s", node.Status, findReason(node))
+	}
+	if node.Truncated != "" && node.Status != "Cycle" {
+		status = fmt.Sprintf("%s (%s)", status, node.Truncated)
+	}
+
+	name := treeCell{text: prefix + fmt.Sprintf("%s/%s", node.Kind, qualifiedName(node.Namespace, node.Name))}
+	if node.Highlighted {
+		// Mark the resource the diagnosis was started from
+		marker := " ◀"
+		if opts.ASCII {
+			marker = " <-"
+		}
+		name = treeCell{text: name.text + marker, color: colorBold}
+	}
+
+	rows = append(rows, []treeCell{
+		name,
+		conditionCell(node.Synced),
+		conditionCell(node.Ready),
+		{text: truncate(status, opts.MaxReasonWidth), color: statusColor(node.Status)},
+	})
+
+	for i, child := range node.Children {
+		branch, next := glyphs.branch, glyphs.pipe
+		if i == len(node.Children)-1 {
+			branch, next = glyphs.last, glyphs.space
+		}
+		rows = appendTreeRows(rows, &child, childPrefix+branch, childPrefix+next, glyphs, opts)
+	}
+	return rows
+}
+
+func writeTreeRow(w io.Writer, row []treeCell, widths []int, color bool) error {
+	var sb strings.Builder
+	for i, c := range row {
+		text := c.text
+		if i < len(row)-1 {
+			text += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c.text)+2)
+		}
+		if color && c.color != "" {
+			// Color only the value so padding stays aligned
+			text = c.color + c.text + colorReset + text[len(c.text):]
+		}
+		sb.WriteString(text)
+	}
+	_, err := fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+	return err
+}
+
+func conditionCell(value string) treeCell {
+	if value == "" {
+		return treeCell{text: "-"}
+	}
+	switch value {
+	case "True":
+		return treeCell{text: value, color: colorGreen}
+	case "False":
+		return treeCell{text: value, color: colorRed}
+	default:
+		return treeCell{text: value, color: colorYellow}
+	}
+}
+
+func statusColor(status string) string {
+	switch statusLevel(status) {
+	case levelOK:
+		return colorGreen
+	case levelError:
+		return colorRed
+	default:
+		return colorYellow
+	}
+}
+
+// truncate flattens s onto one line and shortens it to at most max runes,
+// marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
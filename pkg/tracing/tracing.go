@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "crossplane-diagnose"
+	tracerName  = "github.com/vinishsoman/crossplane-diagnose"
+)
+
+// Setup installs a global tracer provider that exports spans to an OTLP/HTTP
+// endpoint and/or a local file. When neither is configured tracing stays a no-op.
+// The returned function flushes pending spans and must be called before exit.
+func Setup(ctx context.Context, otlpEndpoint, file string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if otlpEndpoint == "" && file == "" {
+		return noop, nil
+	}
+
+	var opts []sdktrace.TracerProviderOption
+	var closers []func() error
+
+	if otlpEndpoint != "" {
+		var exporterOpt otlptracehttp.Option
+		if strings.Contains(otlpEndpoint, "://") {
+			exporterOpt = otlptracehttp.WithEndpointURL(otlpEndpoint)
+		} else {
+			exporterOpt = otlptracehttp.WithEndpoint(otlpEndpoint)
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpt)
+		if err != nil {
+			return noop, fmt.Errorf("failed to create OTLP exporter: %v", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return noop, fmt.Errorf("failed to create trace file %s: %v", file, err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return noop, fmt.Errorf("failed to create file exporter: %v", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		closers = append(closers, f.Close)
+	}
+
+	opts = append(opts, sdktrace.WithResource(resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	)))
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		errs := []error{provider.Shutdown(ctx)}
+		for _, c := range closers {
+			errs = append(errs, c())
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// Start begins a span using the global tracer provider
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,190 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Events APIs in order of preference
+var (
+	eventsV1GVR   = schema.GroupVersionResource{Group: "events.k8s.io", Version: "v1", Resource: "events"}
+	coreEventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+)
+
+// event is a deduplicated event about a resource
+type event struct {
+	Type     string
+	Reason   string
+	Message  string
+	Count    int
+	LastSeen time.Time
+}
+
+// String formats the event as "[Type] Reason: Message", followed by how often
+// it was seen when it repeated
+func (e event) String() string {
+	s := fmt.Sprintf("[%s] %s: %s", e.Type, e.Reason, e.Message)
+	if e.Count > 1 {
+		s += fmt.Sprintf(" (x%d)", e.Count)
+	}
+	return s
+}
+
+// fetchEvents returns the aggregated events about an object, preferring the
+// events.k8s.io API and falling back to the core API. Events are matched on
+// the object's UID, so events about a deleted and recreated object with the
+// same name, or another kind's object of that name, are not included.
+func (b *Builder) fetchEvents(ctx context.Context, obj *unstructured.Unstructured) (_ []string, err error) {
+	namespace := obj.GetNamespace()
+	ctx, span := tracing.Start(ctx, "FetchEvents",
+		attribute.String("k8s.object.kind", obj.GetKind()),
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.object.name", obj.GetName()),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	gvrs := []schema.GroupVersionResource{eventsV1GVR, coreEventsGVR}
+	if b.eventsGVR != nil {
+		gvrs = []schema.GroupVersionResource{*b.eventsGVR}
+	}
+
+	var list *unstructured.UnstructuredList
+	for _, gvr := range gvrs {
+		list, err = b.listEvents(ctx, gvr, namespace, eventSelector(gvr, obj))
+		if err == nil {
+			b.eventsGVR = &gvr
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []string
+	for _, e := range b.aggregateEvents(list.Items, time.Now()) {
+		events = append(events, e.String())
+	}
+	return events, nil
+}
+
+// listEvents lists the events matching selector in the object's namespace.
+// Events about cluster-scoped objects can be in any namespace; without access
+// to all namespaces they are looked up in the default namespace, where
+// Crossplane records them.
+func (b *Builder) listEvents(ctx context.Context, gvr schema.GroupVersionResource, namespace, selector string) (*unstructured.UnstructuredList, error) {
+	opts := metav1.ListOptions{FieldSelector: selector}
+	if namespace != "" {
+		return b.List(ctx, gvr, namespace, opts)
+	}
+
+	if !b.clusterEventsForbidden {
+		list, err := b.List(ctx, gvr, "", opts)
+		if !apierrors.IsForbidden(err) {
+			return list, err
+		}
+		b.clusterEventsForbidden = true
+	}
+	return b.List(ctx, gvr, metav1.NamespaceDefault, opts)
+}
+
+// eventSelector selects the events about obj. events.k8s.io/v1 refers to the
+// object as regarding, the core API as involvedObject. The API version is not
+// part of the selector since events may be recorded against another served
+// version of the object.
+func eventSelector(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) string {
+	prefix := "involvedObject"
+	if gvr == eventsV1GVR {
+		prefix = "regarding"
+	}
+	if obj.GetUID() == "" {
+		return fmt.Sprintf("%s.kind=%s,%s.name=%s", prefix, obj.GetKind(), prefix, obj.GetName())
+	}
+	return fmt.Sprintf("%s.uid=%s", prefix, obj.GetUID())
+}
+
+// aggregateEvents merges events with the same type, reason and message, drops
+// those outside the configured types and time window, and sorts them with the
+// most recently seen first
+func (b *Builder) aggregateEvents(items []unstructured.Unstructured, now time.Time) []event {
+	byKey := make(map[string]*event)
+	var events []*event
+	for _, item := range items {
+		e := parseEvent(item)
+		if len(b.EventTypes) > 0 && !containsFold(b.EventTypes, e.Type) {
+			continue
+		}
+		if b.EventsSince > 0 && !e.LastSeen.IsZero() && now.Sub(e.LastSeen) > b.EventsSince {
+			continue
+		}
+
+		key := e.Type + "\x00" + e.Reason + "\x00" + e.Message
+		if existing, ok := byKey[key]; ok {
+			existing.Count += e.Count
+			if e.LastSeen.After(existing.LastSeen) {
+				existing.LastSeen = e.LastSeen
+			}
+			continue
+		}
+		byKey[key] = &e
+		events = append(events, &e)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.After(events[j].LastSeen)
+	})
+
+	result := make([]event, 0, len(events))
+	for _, e := range events {
+		result = append(result, *e)
+	}
+	return result
+}
+
+// parseEvent reads an event from either the core or the events.k8s.io API
+func parseEvent(item unstructured.Unstructured) event {
+	e := event{Count: 1}
+	e.Type, _, _ = unstructured.NestedString(item.Object, "type")
+	e.Reason, _, _ = unstructured.NestedString(item.Object, "reason")
+
+	// events.k8s.io/v1 calls the message a note
+	e.Message, _, _ = unstructured.NestedString(item.Object, "message")
+	if e.Message == "" {
+		e.Message, _, _ = unstructured.NestedString(item.Object, "note")
+	}
+
+	for _, path := range [][]string{{"series", "count"}, {"count"}, {"deprecatedCount"}} {
+		if count, found, _ := unstructured.NestedInt64(item.Object, path...); found && count > 0 {
+			e.Count = int(count)
+			break
+		}
+	}
+
+	// The last time the event was seen, from the most to the least precise field
+	for _, path := range [][]string{{"series", "lastObservedTime"}, {"lastTimestamp"}, {"deprecatedLastTimestamp"}, {"eventTime"}, {"metadata", "creationTimestamp"}} {
+		value, _, _ := unstructured.NestedString(item.Object, path...)
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			e.LastSeen = t
+			break
+		}
+	}
+	return e
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
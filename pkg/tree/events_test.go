@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var eventsNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// coreEvent builds a core/v1 event last seen ago before eventsNow
+func coreEvent(eventType, reason, message string, count int64, ago time.Duration) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"type":          eventType,
+		"reason":        reason,
+		"message":       message,
+		"count":         count,
+		"lastTimestamp": eventsNow.Add(-ago).Format(time.RFC3339),
+	}}
+}
+
+func TestAggregateEvents(t *testing.T) {
+	eventsV1 := unstructured.Unstructured{Object: map[string]interface{}{
+		"type":   "Warning",
+		"reason": "CannotObserve",
+		"note":   "denied",
+		"series": map[string]interface{}{
+			"count":            int64(4),
+			"lastObservedTime": eventsNow.Add(-time.Minute).Format(time.RFC3339Nano),
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		types  []string
+		since  time.Duration
+		events []unstructured.Unstructured
+		want   []string
+	}{
+		{
+			name: "merges repeats and sorts by last seen",
+			events: []unstructured.Unstructured{
+				coreEvent("Normal", "Created", "created", 1, 3*time.Hour),
+				coreEvent("Warning", "CannotConnect", "timeout", 2, 2*time.Hour),
+				coreEvent("Warning", "CannotConnect", "timeout", 3, 10*time.Minute),
+			},
+			want: []string{"[Warning] CannotConnect: timeout (x5)", "[Normal] Created: created"},
+		},
+		{
+			name:   "reads events.k8s.io notes and series",
+			events: []unstructured.Unstructured{coreEvent("Warning", "CannotConnect", "timeout", 1, time.Hour), eventsV1},
+			want:   []string{"[Warning] CannotObserve: denied (x4)", "[Warning] CannotConnect: timeout"},
+		},
+		{
+			name:   "filters types case-insensitively",
+			types:  []string{"warning"},
+			events: []unstructured.Unstructured{coreEvent("Normal", "Created", "created", 1, 0), coreEvent("Warning", "Failed", "boom", 1, 0)},
+			want:   []string{"[Warning] Failed: boom"},
+		},
+		{
+			name:   "drops events outside the window",
+			since:  time.Hour,
+			events: []unstructured.Unstructured{coreEvent("Warning", "Old", "old", 1, 2*time.Hour), coreEvent("Warning", "New", "new", 1, 30*time.Minute)},
+			want:   []string{"[Warning] New: new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{EventTypes: tt.types, EventsSince: tt.since}
+			var got []string
+			for _, e := range b.aggregateEvents(tt.events, eventsNow) {
+				got = append(got, e.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aggregateEvents() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
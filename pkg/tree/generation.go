@@ -0,0 +1,85 @@
+package tree
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// checkGeneration marks an otherwise healthy node as Progressing or Stale when
+// its controller has not yet observed the latest generation of its spec.
+// observed holds the observedGeneration of each of its conditions.
+func (b *Builder) checkGeneration(node *report.ResourceStatus, obj *unstructured.Unstructured, observed []int64) {
+	generation := obj.GetGeneration()
+	if g, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found {
+		observed = append(observed, g)
+	}
+	// Controllers that do not report observedGeneration cannot be checked
+	if generation == 0 || len(observed) == 0 {
+		return
+	}
+
+	oldest := observed[0]
+	for _, g := range observed[1:] {
+		if g < oldest {
+			oldest = g
+		}
+	}
+	if oldest >= generation {
+		return
+	}
+
+	node.Generation = generation
+	node.ObservedGeneration = oldest
+
+	// The controller is behind since the spec was last written, or since its
+	// conditions last changed if that is more recent. Without either, the
+	// creation time still lets a resource that never caught up become Stale.
+	since := lastSpecUpdate(obj)
+	if node.LastTransitionTime != nil && (since == nil || node.LastTransitionTime.After(*since)) {
+		since = node.LastTransitionTime
+	}
+	if created := obj.GetCreationTimestamp(); since == nil && !created.IsZero() {
+		since = &created.Time
+	}
+	node.PendingSince = since
+
+	if since != nil && time.Since(*since) > b.StaleAfter {
+		node.Status = "Stale"
+	} else {
+		node.Status = "Progressing"
+	}
+}
+
+// lastSpecUpdate returns the time of the latest write to the resource's spec,
+// as recorded in its managed fields. Writes to the status or only to metadata,
+// such as labels and annotations, do not change the generation.
+func lastSpecUpdate(obj *unstructured.Unstructured) *time.Time {
+	var latest *time.Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Subresource != "" || entry.Time == nil || !ownsSpec(entry) {
+			continue
+		}
+		if latest == nil || entry.Time.After(*latest) {
+			t := entry.Time.Time
+			latest = &t
+		}
+	}
+	return latest
+}
+
+// ownsSpec reports whether a managed fields entry covers fields of the spec
+func ownsSpec(entry metav1.ManagedFieldsEntry) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["f:spec"]
+	return ok
+}
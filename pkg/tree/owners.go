@@ -0,0 +1,137 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/restmapper"
+)
+
+// Labels Crossplane sets on composed resources and composites
+const (
+	LabelComposite      = "crossplane.io/composite"
+	LabelClaimName      = "crossplane.io/claim-name"
+	LabelClaimNamespace = "crossplane.io/claim-namespace"
+)
+
+// maxOwnerDepth bounds the walk up in case of unexpected owner chains
+const maxOwnerDepth = 10
+
+// Ref is a fetched resource together with its resource type
+type Ref struct {
+	GVR    schema.GroupVersionResource
+	Object *unstructured.Unstructured
+}
+
+// FindRoot walks up from the given resource to the claim or composite that owns
+// it, following controller owner references, a composite's spec.claimRef and
+// finally the crossplane.io composite and claim labels. It returns the chain
+// from the root down to the starting resource.
+func (b *Builder) FindRoot(ctx context.Context, mapper meta.RESTMapper, categories restmapper.CategoryExpander, gvr schema.GroupVersionResource, namespace, name string) (_ []Ref, err error) {
+	ctx, span := tracing.Start(ctx, "FindRoot",
+		attribute.String("k8s.resource", gvr.String()),
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.object.name", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	obj, err := b.Get(ctx, gvr, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s: %v", gvr.Resource, name, err)
+	}
+
+	chain := []Ref{{GVR: gvr, Object: obj}}
+	visited := map[types.UID]bool{obj.GetUID(): true}
+
+	for len(chain) < maxOwnerDepth {
+		parentGVR, parent := b.findParent(ctx, mapper, categories, obj)
+		if parent == nil || visited[parent.GetUID()] {
+			break
+		}
+		visited[parent.GetUID()] = true
+		chain = append([]Ref{{GVR: parentGVR, Object: parent}}, chain...)
+		obj = parent
+	}
+	return chain, nil
+}
+
+// findParent returns the owner of obj, or nil if it has none that can be fetched
+func (b *Builder) findParent(ctx context.Context, mapper meta.RESTMapper, categories restmapper.CategoryExpander, obj *unstructured.Unstructured) (schema.GroupVersionResource, *unstructured.Unstructured) {
+	// Composed resources are controlled by their composite
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if gvr, parent := b.getRef(ctx, mapper, owner.APIVersion, owner.Kind, obj.GetNamespace(), owner.Name); parent != nil {
+			return gvr, parent
+		}
+	}
+
+	// Composites point back at the claim that created them
+	if claimRef, found, _ := unstructured.NestedStringMap(obj.Object, "spec", "claimRef"); found {
+		if gvr, parent := b.getRef(ctx, mapper, claimRef["apiVersion"], claimRef["kind"], claimRef["namespace"], claimRef["name"]); parent != nil {
+			return gvr, parent
+		}
+	}
+
+	// Without references the labels still name the composite and claim, but not their kind
+	labels := obj.GetLabels()
+	if composite := labels[LabelComposite]; composite != "" && composite != obj.GetName() {
+		if gvr, parent := b.getByCategory(ctx, mapper, categories, "composite", obj.GetNamespace(), composite); parent != nil {
+			return gvr, parent
+		}
+	}
+	if claim := labels[LabelClaimName]; claim != "" && labels[LabelClaimNamespace] != "" {
+		if gvr, parent := b.getByCategory(ctx, mapper, categories, "claim", labels[LabelClaimNamespace], claim); parent != nil {
+			return gvr, parent
+		}
+	}
+	return schema.GroupVersionResource{}, nil
+}
+
+// getRef fetches the resource described by an object reference. namespace is
+// ignored for cluster-scoped kinds.
+func (b *Builder) getRef(ctx context.Context, mapper meta.RESTMapper, apiVersion, kind, namespace, name string) (schema.GroupVersionResource, *unstructured.Unstructured) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil || kind == "" || name == "" {
+		return schema.GroupVersionResource{}, nil
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, nil
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = ""
+	}
+
+	obj, err := b.Get(ctx, mapping.Resource, namespace, name)
+	if err != nil {
+		return schema.GroupVersionResource{}, nil
+	}
+	return mapping.Resource, obj
+}
+
+// getByCategory looks for a resource with the given name among the types of a category
+func (b *Builder) getByCategory(ctx context.Context, mapper meta.RESTMapper, categories restmapper.CategoryExpander, category, namespace, name string) (schema.GroupVersionResource, *unstructured.Unstructured) {
+	grs, _ := categories.Expand(category)
+	for _, gr := range grs {
+		gvr, err := mapper.ResourceFor(gr.WithVersion(""))
+		if err != nil {
+			continue
+		}
+		gvk, err := mapper.KindFor(gvr)
+		if err != nil {
+			continue
+		}
+		if parentGVR, parent := b.getRef(ctx, mapper, gvk.GroupVersion().String(), gvk.Kind, namespace, name); parent != nil {
+			return parentGVR, parent
+		}
+	}
+	return schema.GroupVersionResource{}, nil
+}
@@ -0,0 +1,45 @@
+package tree
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AnnotationPaused stops Crossplane from reconciling a resource when set to "true"
+const AnnotationPaused = "crossplane.io/paused"
+
+// partialPolicies returns the managementPolicies of a managed resource, or nil
+// when Crossplane fully manages it. The single managementPolicy field of older
+// providers is translated to the equivalent policies.
+func partialPolicies(obj *unstructured.Unstructured) []string {
+	policies, found, _ := unstructured.NestedStringSlice(obj.Object, "spec", "managementPolicies")
+	if !found {
+		legacy, _, _ := unstructured.NestedString(obj.Object, "spec", "managementPolicy")
+		switch legacy {
+		case "ObserveOnly":
+			policies = []string{"Observe"}
+		case "OrphanOnDelete":
+			policies = []string{"Observe", "Create", "Update", "LateInitialize"}
+		}
+	}
+	for _, p := range policies {
+		if p == "*" {
+			return nil
+		}
+	}
+	return policies
+}
+
+// isObserveOnly reports whether the policies only allow observing the external
+// resource. Such a resource is not created or updated, so it may never become
+// Ready while still being observed successfully.
+func isObserveOnly(policies []string) bool {
+	if len(policies) == 0 {
+		return false
+	}
+	for _, p := range policies {
+		if p != "Observe" {
+			return false
+		}
+	}
+	return true
+}
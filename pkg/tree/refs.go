@@ -0,0 +1,59 @@
+package tree
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ObjectRef is a reference from one resource to another, such as an entry of
+// a composite's spec.resourceRefs or a claim's spec.resourceRef
+type ObjectRef struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// ResourceRefs returns the complete entries of a composite's spec.resourceRefs.
+// Namespaced composites can only compose resources in their own namespace, so
+// entries without a namespace are in the composite's namespace.
+func ResourceRefs(obj *unstructured.Unstructured) []ObjectRef {
+	refs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "resourceRefs")
+
+	var result []ObjectRef
+	for _, ref := range refs {
+		refMap, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if r, ok := objectRef(refMap, obj.GetNamespace()); ok {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// claimResourceRef returns the composite a claim points at via spec.resourceRef
+func claimResourceRef(obj *unstructured.Unstructured) (ObjectRef, bool) {
+	ref, found, _ := unstructured.NestedMap(obj.Object, "spec", "resourceRef")
+	if !found {
+		return ObjectRef{}, false
+	}
+	return objectRef(ref, "")
+}
+
+// objectRef reads a reference, defaulting its namespace. References without an
+// apiVersion, kind or name are incomplete and skipped.
+func objectRef(ref map[string]interface{}, defaultNamespace string) (ObjectRef, bool) {
+	r := ObjectRef{}
+	r.APIVersion, _ = ref["apiVersion"].(string)
+	r.Kind, _ = ref["kind"].(string)
+	r.Name, _ = ref["name"].(string)
+	r.Namespace, _ = ref["namespace"].(string)
+	if r.APIVersion == "" || r.Kind == "" || r.Name == "" {
+		return ObjectRef{}, false
+	}
+	if r.Namespace == "" {
+		r.Namespace = defaultNamespace
+	}
+	return r, true
+}
@@ -0,0 +1,127 @@
+package tree
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// Defaults for the API requests made while building trees
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultRetries        = 3
+)
+
+// requestBackoff is the delay between retries of a failed request
+var requestBackoff = wait.Backoff{
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Cap:      5 * time.Second,
+}
+
+// do runs an API request with the per-request timeout, retrying transient
+// failures with exponential backoff until the retries or ctx run out
+func (b *Builder) do(ctx context.Context, request func(context.Context) error) error {
+	backoff := requestBackoff
+	backoff.Steps = b.Retries + 1
+	for attempt := 0; ; attempt++ {
+		err := b.attempt(ctx, request)
+		if err == nil || attempt >= b.Retries || ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+func (b *Builder) attempt(ctx context.Context, request func(context.Context) error) error {
+	if b.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.RequestTimeout)
+		defer cancel()
+	}
+	return request(ctx)
+}
+
+// Get fetches a resource, retrying transient failures. namespace is empty for
+// cluster-scoped resources.
+func (b *Builder) Get(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (obj *unstructured.Unstructured, err error) {
+	var client dynamic.ResourceInterface = b.client.Resource(gvr)
+	if namespace != "" {
+		client = b.client.Resource(gvr).Namespace(namespace)
+	}
+	err = b.do(ctx, func(ctx context.Context) (err error) {
+		obj, err = client.Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	return obj, err
+}
+
+// List lists resources, retrying transient failures. namespace is empty for
+// cluster-scoped resources or to list across all namespaces.
+func (b *Builder) List(ctx context.Context, gvr schema.GroupVersionResource, namespace string, opts metav1.ListOptions) (list *unstructured.UnstructuredList, err error) {
+	var client dynamic.ResourceInterface = b.client.Resource(gvr)
+	if namespace != "" {
+		client = b.client.Resource(gvr).Namespace(namespace)
+	}
+	err = b.do(ctx, func(ctx context.Context) (err error) {
+		list, err = client.List(ctx, opts)
+		return err
+	})
+	return list, err
+}
+
+// IsTransient reports whether a request failed for a reason that may go away
+// when it is retried: throttling, server errors, timeouts and dropped connections
+func IsTransient(err error) bool {
+	switch {
+	case apierrors.IsTooManyRequests(err),
+		apierrors.IsServerTimeout(err),
+		apierrors.IsTimeout(err),
+		apierrors.IsInternalError(err),
+		apierrors.IsServiceUnavailable(err),
+		apierrors.IsUnexpectedServerError(err):
+		return true
+	case errors.Is(err, context.DeadlineExceeded),
+		utilnet.IsConnectionReset(err),
+		utilnet.IsConnectionRefused(err),
+		utilnet.IsProbableEOF(err):
+		return true
+	}
+
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code >= 500 {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// ErrorType classifies why a request failed as one of the report error types
+func ErrorType(err error) string {
+	switch {
+	case apierrors.IsNotFound(err):
+		return report.ErrorNotFound
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return report.ErrorForbidden
+	case IsTransient(err):
+		return report.ErrorTransient
+	default:
+		return report.ErrorOther
+	}
+}
@@ -0,0 +1,109 @@
+package tree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var bucketsGR = schema.GroupResource{Group: "example.org", Resource: "buckets"}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+		want      string
+	}{
+		{name: "not found", err: apierrors.NewNotFound(bucketsGR, "b"), want: report.ErrorNotFound},
+		{name: "forbidden", err: apierrors.NewForbidden(bucketsGR, "b", errors.New("denied")), want: report.ErrorForbidden},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("expired"), want: report.ErrorForbidden},
+		{name: "throttled", err: apierrors.NewTooManyRequests("slow down", 1), transient: true, want: report.ErrorTransient},
+		{name: "server timeout", err: apierrors.NewServerTimeout(bucketsGR, "get", 1), transient: true, want: report.ErrorTransient},
+		{name: "internal error", err: apierrors.NewInternalError(errors.New("etcd")), transient: true, want: report.ErrorTransient},
+		{name: "service unavailable", err: apierrors.NewServiceUnavailable("restarting"), transient: true, want: report.ErrorTransient},
+		{name: "deadline", err: fmt.Errorf("get: %w", context.DeadlineExceeded), transient: true, want: report.ErrorTransient},
+		{name: "connection refused", err: &wrappedErrno{syscall.ECONNREFUSED}, transient: true, want: report.ErrorTransient},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, transient: true, want: report.ErrorTransient},
+		{name: "bad request", err: apierrors.NewBadRequest("invalid"), want: report.ErrorOther},
+		{name: "canceled", err: context.Canceled, want: report.ErrorOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.transient {
+				t.Errorf("IsTransient() = %v, want %v", got, tt.transient)
+			}
+			if got := ErrorType(tt.err); got != tt.want {
+				t.Errorf("ErrorType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// wrappedErrno wraps a system call error like the net package does
+type wrappedErrno struct{ errno syscall.Errno }
+
+func (e *wrappedErrno) Error() string { return "dial tcp: " + e.errno.Error() }
+func (e *wrappedErrno) Unwrap() error { return e.errno }
+
+func TestRequestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []error
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "transient failure is retried", failures: []error{apierrors.NewTooManyRequests("slow down", 0)}, retries: 2, wantCalls: 2},
+		{name: "retries run out", failures: []error{apierrors.NewServiceUnavailable("a"), apierrors.NewServiceUnavailable("b")}, retries: 1, wantCalls: 2, wantErr: true},
+		{name: "permanent failure is not retried", failures: []error{apierrors.NewForbidden(bucketsGR, "b", errors.New("denied"))}, retries: 3, wantCalls: 1, wantErr: true},
+	}
+	requests := map[string]func(b *Builder) error{
+		"get": func(b *Builder) error {
+			_, err := b.Get(context.Background(), bucketsGR.WithVersion("v1"), "", "b")
+			return err
+		},
+		"list": func(b *Builder) error {
+			_, err := b.List(context.Background(), bucketsGR.WithVersion("v1"), "", metav1.ListOptions{})
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for verb, request := range requests {
+			t.Run(verb+" "+tt.name, func(t *testing.T) {
+				saved := requestBackoff
+				requestBackoff.Duration = 0
+				t.Cleanup(func() { requestBackoff = saved })
+
+				b := newFakeBuilder(resource("Bucket", "b"))
+				b.Retries = tt.retries
+				calls := 0
+				b.client.(*fake.FakeDynamicClient).PrependReactor(verb, "buckets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					calls++
+					if calls <= len(tt.failures) {
+						return true, nil, tt.failures[calls-1]
+					}
+					return false, nil, nil
+				})
+
+				if err := request(b); (err != nil) != tt.wantErr {
+					t.Errorf("%s error = %v, want error %v", verb, err, tt.wantErr)
+				}
+				if calls != tt.wantCalls {
+					t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+				}
+			})
+		}
+	}
+}
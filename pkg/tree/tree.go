@@ -4,44 +4,117 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 )
 
+// DefaultStaleAfter is how long a controller may take to observe a new generation
+// before the resource is reported as Stale rather than Progressing
+const DefaultStaleAfter = 10 * time.Minute
+
+// DefaultMaxDepth is how many levels of references are followed below a root
+const DefaultMaxDepth = 10
+
 // Builder handles tree construction
 type Builder struct {
 	client dynamic.Interface
+	// RequestTimeout bounds each API request; zero leaves requests unbounded
+	RequestTimeout time.Duration
+	// Retries is how often a request that failed transiently is retried
+	Retries int
+	// MaxDepth is how many levels of references are followed below the root;
+	// zero follows them without limit
+	MaxDepth int
+	// StaleAfter is how long a resource may lag behind its latest generation
+	// before it is reported as Stale
+	StaleAfter time.Duration
+	// EventsSince drops events last seen longer ago than this; zero keeps all
+	EventsSince time.Duration
+	// EventTypes limits events to these types, such as Warning; empty keeps all
+	EventTypes []string
+	// eventsGVR is the events API that answered last, tried first for later lookups
+	eventsGVR *schema.GroupVersionResource
+	// clusterEventsForbidden records that events cannot be listed in all namespaces
+	clusterEventsForbidden bool
+	// usages caches the Usages in the cluster, listed the first time a tree has a deletion
+	usages []usage
+	// usagesListed reports whether usages has been populated
+	usagesListed bool
 }
 
 // NewBuilder creates a new Builder
 func NewBuilder(client dynamic.Interface) *Builder {
-	return &Builder{client: client}
+	return &Builder{
+		client:         client,
+		RequestTimeout: DefaultRequestTimeout,
+		Retries:        DefaultRetries,
+		MaxDepth:       DefaultMaxDepth,
+		StaleAfter:     DefaultStaleAfter,
+	}
 }
 
-// BuildTree constructs a tree for a given Composite Resource
-func (b *Builder) BuildTree(ctx context.Context, gvr schema.GroupVersionResource, name string) (*report.ResourceStatus, error) {
-	xr, err := b.client.Resource(gvr).Get(ctx, name, metav1.GetOptions{})
+// BuildTree constructs a tree for a given Composite Resource or Claim.
+// namespace is empty for cluster-scoped resources.
+func (b *Builder) BuildTree(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (_ *report.ResourceStatus, err error) {
+	ctx, span := tracing.Start(ctx, "BuildTree",
+		attribute.String("k8s.resource", gvr.String()),
+		attribute.String("k8s.namespace.name", namespace),
+		attribute.String("k8s.object.name", name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	xr, err := b.Get(ctx, gvr, namespace, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get XR %s: %v", name, err)
+		return nil, fmt.Errorf("failed to get XR %s: %w", name, err)
 	}
 
 	// 2. Build Tree Recursively
-	return b.buildNodeRecursive(ctx, xr), nil
+	root := b.buildNodeRecursive(ctx, xr, newWalk(), 0)
+
+	// 3. Explain what keeps deleting resources around
+	if hasDeletion(root) {
+		b.markBlockingUsages(ctx, root)
+	}
+	return root, nil
+}
+
+// walk tracks the resources visited while building one tree
+type walk struct {
+	// ancestors holds the UIDs of the resources on the path from the root
+	ancestors map[types.UID]bool
+	// shown holds the resources already built elsewhere in the tree
+	shown map[types.UID]*report.ResourceStatus
+}
+
+func newWalk() *walk {
+	return &walk{
+		ancestors: make(map[types.UID]bool),
+		shown:     make(map[types.UID]*report.ResourceStatus),
+	}
 }
 
-func (b *Builder) buildNodeRecursive(ctx context.Context, obj *unstructured.Unstructured) *report.ResourceStatus {
+// buildNodeRecursive builds the node for obj and its children. depth is the
+// length of the path from the root.
+func (b *Builder) buildNodeRecursive(ctx context.Context, obj *unstructured.Unstructured, w *walk, depth int) *report.ResourceStatus {
 	node := &report.ResourceStatus{
-		Kind:   obj.GetKind(),
-		Name:   obj.GetName(),
-		Synced: "Unknown",
-		Ready:  "Unknown",
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Synced:     "Unknown",
+		Ready:      "Unknown",
 	}
 
 	// Extract Status
+	var observed []int64
 	conditions, found, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
 	if found {
 		for _, c := range conditions {
@@ -53,6 +126,17 @@ func (b *Builder) buildNodeRecursive(ctx context.Context, obj *unstructured.Unst
 			cStatus, _ := cond["status"].(string)
 			cReason, _ := cond["reason"].(string)
 			cMessage, _ := cond["message"].(string)
+			cTransition, _ := cond["lastTransitionTime"].(string)
+
+			if t, err := time.Parse(time.RFC3339, cTransition); err == nil {
+				if node.LastTransitionTime == nil || t.After(*node.LastTransitionTime) {
+					node.LastTransitionTime = &t
+				}
+			}
+
+			if g, ok := cond["observedGeneration"].(int64); ok {
+				observed = append(observed, g)
+			}
 
 			if cType == "Synced" {
 				node.Synced = cStatus
@@ -65,104 +149,134 @@ func (b *Builder) buildNodeRecursive(ctx context.Context, obj *unstructured.Unst
 		}
 	}
 
-	// Determine overall status
-	if node.Ready == "True" && node.Synced == "True" {
+	// Paused and partially managed resources are not expected to reconcile fully
+	node.Paused = obj.GetAnnotations()[AnnotationPaused] == "true"
+	node.ManagementPolicies = partialPolicies(obj)
+
+	// Determine overall status. A resource being deleted is reported as
+	// Deleting regardless of its conditions, together with what it waits on.
+	if ts := obj.GetDeletionTimestamp(); ts != nil {
+		node.Status = "Deleting"
+		node.DeletionTimestamp = &ts.Time
+		node.Finalizers = obj.GetFinalizers()
+		node.DeletionPolicy, _, _ = unstructured.NestedString(obj.Object, "spec", "deletionPolicy")
+	} else if node.Ready == "True" && node.Synced == "True" {
 		node.Status = "Available"
+		b.checkGeneration(node, obj, observed)
+	} else if node.Paused {
+		node.Status = "Paused"
+	} else if isObserveOnly(node.ManagementPolicies) && node.Synced == "True" {
+		// Observing succeeded, so only readiness is missing, which the policy
+		// explains; a failed observation is a genuine failure
+		node.Status = "ObserveOnly"
 	} else {
 		node.Status = "Unhealthy"
 	}
 
 	// Fetch Events
-	events, err := b.fetchEvents(ctx, obj.GetKind(), obj.GetName(), obj.GetNamespace())
+	events, err := b.fetchEvents(ctx, obj)
 	if err == nil {
 		node.Events = events
+	} else if apierrors.IsForbidden(err) {
+		node.EventsForbidden = true
 	}
 
 	// Find Children (Managed Resources) via spec.resourceRefs
-	refs, found, err := unstructured.NestedSlice(obj.Object, "spec", "resourceRefs")
-	if err == nil && found {
-		for _, ref := range refs {
-			refMap, ok := ref.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	refs := ResourceRefs(obj)
 
-			// Extract reference details
-			apiVersion, _ := refMap["apiVersion"].(string)
-			kind, _ := refMap["kind"].(string)
-			refName, _ := refMap["name"].(string)
+	// Claims point at their cluster-scoped Composite Resource via spec.resourceRef
+	claimRef, hasClaimRef := claimResourceRef(obj)
 
-			if apiVersion == "" || kind == "" || refName == "" {
-				continue
-			}
-
-			// Parse GroupVersion
-			gv, err := schema.ParseGroupVersion(apiVersion)
-			if err != nil {
-				continue
-			}
-
-			// Naive pluralization
-			resource := strings.ToLower(kind) + "s"
-
-			childGVR := schema.GroupVersionResource{
-				Group:    gv.Group,
-				Version:  gv.Version,
-				Resource: resource,
-			}
+	if len(refs) == 0 && !hasClaimRef {
+		return node
+	}
+	if b.MaxDepth > 0 && depth >= b.MaxDepth {
+		node.Truncated = fmt.Sprintf("depth limit of %d reached", b.MaxDepth)
+		return node
+	}
 
-			childObj, err := b.client.Resource(childGVR).Get(ctx, refName, metav1.GetOptions{})
-			if err != nil {
-				node.Children = append(node.Children, report.ResourceStatus{
-					Kind:   kind,
-					Name:   refName,
-					Status: fmt.Sprintf("Error fetching: %v", err),
-				})
-				continue
-			}
+	w.ancestors[obj.GetUID()] = true
+	defer delete(w.ancestors, obj.GetUID())
 
-			// Recursively build child node
-			childNode := b.buildNodeRecursive(ctx, childObj)
-			node.Children = append(node.Children, *childNode)
-		}
+	for _, ref := range refs {
+		b.appendChild(ctx, node, ref, w, depth)
+	}
+	if hasClaimRef {
+		b.appendChild(ctx, node, claimRef, w, depth)
 	}
 
 	return node
 }
 
-func (b *Builder) fetchEvents(ctx context.Context, kind, name, namespace string) ([]string, error) {
-	// Events are namespaced if the object is namespaced.
-	// If namespace is empty, it might be cluster scoped, but events for cluster scoped objects are usually in default or specific namespace?
-	// Actually events are always namespaced. For cluster scoped resources, events are often in 'default'.
-	// But let's try the object's namespace if present, or 'default' if not?
-	// Safest is to list all events with field selector.
-
-	gvr := schema.GroupVersionResource{Group: "", Version: "v1", Resource: "events"}
+// appendChild fetches the object described by ref and adds it as a child of node
+func (b *Builder) appendChild(ctx context.Context, node *report.ResourceStatus, ref ObjectRef, w *walk, depth int) {
+	kind, refName, refNamespace := ref.Kind, ref.Name, ref.Namespace
 
-	// If namespace is provided, list in that namespace.
-	// If not, list in all namespaces (requires ClusterRole).
-	var client dynamic.ResourceInterface
-	if namespace != "" {
-		client = b.client.Resource(gvr).Namespace(namespace)
-	} else {
-		client = b.client.Resource(gvr) // All namespaces
+	// Parse GroupVersion
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return
 	}
 
-	opts := metav1.ListOptions{
-		FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name),
+	// Naive pluralization
+	resource := strings.ToLower(kind) + "s"
+
+	childGVR := schema.GroupVersionResource{
+		Group:    gv.Group,
+		Version:  gv.Version,
+		Resource: resource,
 	}
 
-	list, err := client.List(ctx, opts)
+	getCtx, span := tracing.Start(ctx, "GetChild",
+		attribute.String("k8s.resource", childGVR.String()),
+		attribute.String("k8s.namespace.name", refNamespace),
+		attribute.String("k8s.object.name", refName),
+	)
+	childObj, err := b.Get(getCtx, childGVR, refNamespace, refName)
+	tracing.End(span, err)
 	if err != nil {
-		return nil, err
+		node.Children = append(node.Children, report.ResourceStatus{
+			APIVersion: ref.APIVersion,
+			Kind:       kind,
+			Namespace:  refNamespace,
+			Name:       refName,
+			Status:     fmt.Sprintf("Error fetching: %v", err),
+			ErrorType:  ErrorType(err),
+		})
+		return
+	}
+
+	// A reference back to a resource on the path from the root would recurse forever
+	uid := childObj.GetUID()
+	if uid != "" && w.ancestors[uid] {
+		node.Children = append(node.Children, report.ResourceStatus{
+			APIVersion: childObj.GetAPIVersion(),
+			Kind:       kind,
+			Namespace:  childObj.GetNamespace(),
+			Name:       refName,
+			Synced:     "Unknown",
+			Ready:      "Unknown",
+			Status:     "Cycle",
+			Truncated:  fmt.Sprintf("references its ancestor %s/%s", kind, refName),
+		})
+		return
+	}
+
+	// A resource referenced from several places, such as one shared by two
+	// composites, is shown in full once and as a reference without children after
+	if shown := w.shown[uid]; uid != "" && shown != nil {
+		repeat := *shown
+		repeat.Children = nil
+		repeat.Events = nil
+		repeat.Truncated = "already shown above"
+		node.Children = append(node.Children, repeat)
+		return
 	}
 
-	var events []string
-	for _, item := range list.Items {
-		reason, _, _ := unstructured.NestedString(item.Object, "reason")
-		message, _, _ := unstructured.NestedString(item.Object, "message")
-		typeStr, _, _ := unstructured.NestedString(item.Object, "type")
-		events = append(events, fmt.Sprintf("[%s] %s: %s", typeStr, reason, message))
+	// Recursively build child node
+	childNode := b.buildNodeRecursive(ctx, childObj, w, depth+1)
+	if uid != "" {
+		w.shown[uid] = childNode
 	}
-	return events, nil
+	node.Children = append(node.Children, *childNode)
 }
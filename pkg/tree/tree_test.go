@@ -0,0 +1,278 @@
+package tree
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+var xappGVR = schema.GroupVersionResource{Group: "example.org", Version: "v1", Resource: "xapps"}
+
+func resource(kind, name string, refs ...string) *unstructured.Unstructured {
+	var resourceRefs []interface{}
+	for i := 0; i+1 < len(refs); i += 2 {
+		resourceRefs = append(resourceRefs, map[string]interface{}{
+			"apiVersion": "example.org/v1",
+			"kind":       refs[i],
+			"name":       refs[i+1],
+		})
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.org/v1",
+		"kind":       kind,
+		"spec":       map[string]interface{}{"resourceRefs": resourceRefs},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Synced", "status": "True"},
+			map[string]interface{}{"type": "Ready", "status": "True"},
+		}},
+	}}
+	obj.SetName(name)
+	obj.SetUID(types.UID(kind + "/" + name))
+	return obj
+}
+
+// condition is a condition given as type, status, reason and observedGeneration
+type condition [4]interface{}
+
+var (
+	ready      = condition{"Ready", "True", "Available", int64(2)}
+	synced     = condition{"Synced", "True", "ReconcileSuccess", int64(2)}
+	notReady   = condition{"Ready", "False", "Creating", int64(2)}
+	syncFailed = condition{"Synced", "False", "ReconcileError", int64(2)}
+)
+
+// withConditions replaces the conditions of obj. A nil observedGeneration or a
+// zero transition time is left out.
+func withConditions(obj *unstructured.Unstructured, transition time.Time, conds ...condition) {
+	var conditions []interface{}
+	for _, c := range conds {
+		cond := map[string]interface{}{"type": c[0], "status": c[1], "reason": c[2]}
+		if c[3] != nil {
+			cond["observedGeneration"] = c[3]
+		}
+		if !transition.IsZero() {
+			cond["lastTransitionTime"] = transition.Format(time.RFC3339)
+		}
+		conditions = append(conditions, cond)
+	}
+	obj.Object["status"] = map[string]interface{}{"conditions": conditions}
+}
+
+// managedFields returns an update to the resource made at the given time
+func managedFields(at time.Time, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    "kubectl",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		Time:       &metav1.Time{Time: at},
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func newFakeBuilder(objects ...runtime.Object) *Builder {
+	listKinds := map[schema.GroupVersionResource]string{
+		xappGVR: "XAppList",
+		{Group: "example.org", Version: "v1", Resource: "xnets"}:   "XNetList",
+		{Group: "example.org", Version: "v1", Resource: "buckets"}: "BucketList",
+		eventsV1GVR:   "EventList",
+		coreEventsGVR: "EventList",
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+	b := NewBuilder(client)
+	b.Retries = 0
+	return b
+}
+
+// shape renders a tree as kind/name(status, truncated)[children] for comparison
+func shape(node *report.ResourceStatus) string {
+	s := node.Kind + "/" + node.Name + "(" + node.Status
+	if node.Truncated != "" {
+		s += ", " + node.Truncated
+	}
+	s += ")"
+	if len(node.Children) > 0 {
+		s += "["
+		for i := range node.Children {
+			if i > 0 {
+				s += " "
+			}
+			s += shape(&node.Children[i])
+		}
+		s += "]"
+	}
+	return s
+}
+
+func TestBuildTreeCyclesAndDepth(t *testing.T) {
+	objects := []runtime.Object{
+		resource("XApp", "app", "XNet", "net", "Bucket", "shared"),
+		resource("XNet", "net", "Bucket", "shared", "XApp", "app"),
+		resource("Bucket", "shared"),
+	}
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		want     string
+	}{
+		{
+			name: "cycles and repeated resources",
+			want: "XApp/app(Available)[" +
+				"XNet/net(Available)[Bucket/shared(Available) XApp/app(Cycle, references its ancestor XApp/app)] " +
+				"Bucket/shared(Available, already shown above)]",
+		},
+		{
+			name:     "depth limit",
+			maxDepth: 1,
+			want: "XApp/app(Available)[" +
+				"XNet/net(Available, depth limit of 1 reached) " +
+				"Bucket/shared(Available)]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBuilder(objects...)
+			b.MaxDepth = tt.maxDepth
+
+			root, err := b.BuildTree(context.Background(), xappGVR, "", "app")
+			if err != nil {
+				t.Fatalf("BuildTree() error = %v", err)
+			}
+			if got := shape(root); got != tt.want {
+				t.Errorf("tree =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTreeCycleConditions(t *testing.T) {
+	b := newFakeBuilder(resource("XApp", "app", "XApp", "app"))
+
+	root, err := b.BuildTree(context.Background(), xappGVR, "", "app")
+	if err != nil {
+		t.Fatalf("BuildTree() error = %v", err)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("got %d children, want 1", len(root.Children))
+	}
+	cycle := root.Children[0]
+	if cycle.Status != "Cycle" || cycle.Synced != "Unknown" || cycle.Ready != "Unknown" {
+		t.Errorf("cycle node = %s synced=%s ready=%s, want Cycle with Unknown conditions", cycle.Status, cycle.Synced, cycle.Ready)
+	}
+}
+
+func TestBuildTreeMissingChild(t *testing.T) {
+	b := newFakeBuilder(resource("XApp", "app", "Bucket", "missing"))
+
+	root, err := b.BuildTree(context.Background(), xappGVR, "", "app")
+	if err != nil {
+		t.Fatalf("BuildTree() error = %v", err)
+	}
+	if len(root.Children) != 1 || root.Children[0].ErrorType != report.ErrorNotFound {
+		t.Errorf("children = %+v, want one NotFound error node", root.Children)
+	}
+}
+
+func TestNodeStatus(t *testing.T) {
+	recent, old := time.Now().Add(-time.Minute), time.Now().Add(-time.Hour)
+	observeOnly := map[string]interface{}{"managementPolicies": []interface{}{"Observe"}}
+
+	tests := []struct {
+		name        string
+		conditions  []condition
+		transition  time.Time
+		untimed     bool
+		generation  int64
+		annotations map[string]string
+		spec        map[string]interface{}
+		fields      []metav1.ManagedFieldsEntry
+		created     time.Time
+		want        string
+	}{
+		{name: "ready and synced", conditions: []condition{ready, synced}, want: "Available"},
+		{name: "not ready", conditions: []condition{notReady, synced}, want: "Unhealthy"},
+		{
+			name:        "paused",
+			conditions:  []condition{notReady, syncFailed},
+			annotations: map[string]string{AnnotationPaused: "true"},
+			want:        "Paused",
+		},
+		{name: "observe-only and synced", conditions: []condition{notReady, synced}, spec: observeOnly, want: "ObserveOnly"},
+		{name: "observe-only failing to sync", conditions: []condition{notReady, syncFailed}, spec: observeOnly, want: "Unhealthy"},
+		{
+			name:       "legacy observe-only policy",
+			conditions: []condition{notReady, synced},
+			spec:       map[string]interface{}{"managementPolicy": "ObserveOnly"},
+			want:       "ObserveOnly",
+		},
+		{name: "new generation not yet observed", conditions: []condition{ready, synced}, transition: recent, generation: 3, want: "Progressing"},
+		{name: "generation lagging behind for too long", conditions: []condition{ready, synced}, generation: 3, want: "Stale"},
+		{
+			name:       "spec written recently",
+			conditions: []condition{ready, synced},
+			generation: 3,
+			fields:     []metav1.ManagedFieldsEntry{managedFields(recent, `{"f:spec":{"f:forProvider":{}}}`)},
+			want:       "Progressing",
+		},
+		{
+			name:       "only metadata written recently",
+			conditions: []condition{ready, synced},
+			generation: 3,
+			fields:     []metav1.ManagedFieldsEntry{managedFields(recent, `{"f:metadata":{"f:labels":{}}}`)},
+			want:       "Stale",
+		},
+		{
+			name:       "no timestamps besides creation",
+			conditions: []condition{ready, synced},
+			untimed:    true,
+			generation: 3,
+			created:    old,
+			want:       "Stale",
+		},
+		{
+			name:       "controller without observedGeneration",
+			conditions: []condition{{"Ready", "True", "Available", nil}, {"Synced", "True", "ReconcileSuccess", nil}},
+			generation: 3,
+			want:       "Available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := resource("Bucket", "b")
+			obj.SetGeneration(2)
+			if tt.generation != 0 {
+				obj.SetGeneration(tt.generation)
+			}
+			transition := old
+			if tt.untimed {
+				transition = time.Time{}
+			} else if !tt.transition.IsZero() {
+				transition = tt.transition
+			}
+			withConditions(obj, transition, tt.conditions...)
+			obj.SetAnnotations(tt.annotations)
+			if tt.spec != nil {
+				obj.Object["spec"] = tt.spec
+			}
+			obj.SetManagedFields(tt.fields)
+			if !tt.created.IsZero() {
+				obj.SetCreationTimestamp(metav1.NewTime(tt.created))
+			}
+
+			b := newFakeBuilder()
+			b.StaleAfter = 10 * time.Minute
+			node := b.buildNodeRecursive(context.Background(), obj, newWalk(), 0)
+			if node.Status != tt.want {
+				t.Errorf("status = %s, want %s", node.Status, tt.want)
+			}
+		})
+	}
+}
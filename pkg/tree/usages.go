@@ -0,0 +1,173 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"github.com/vinishsoman/crossplane-diagnose/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// usageGVRs are the Usage types that can block deletion of a resource. Each
+// entry lists alternative versions of the same type; the first that can be
+// listed is used.
+var usageGVRs = [][]schema.GroupVersionResource{
+	{
+		{Group: "apiextensions.crossplane.io", Version: "v1beta1", Resource: "usages"},
+		{Group: "apiextensions.crossplane.io", Version: "v1alpha1", Resource: "usages"},
+	},
+	{{Group: "protection.crossplane.io", Version: "v1beta1", Resource: "usages"}},
+	{{Group: "protection.crossplane.io", Version: "v1beta1", Resource: "clusterusages"}},
+}
+
+// usage is a Usage that protects a resource from deletion
+type usage struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Of identifies the protected resource
+	OfGroup string
+	OfKind  string
+	OfName  string
+	// By describes what uses the protected resource
+	By string
+}
+
+// String describes the usage for display
+func (u usage) String() string {
+	s := fmt.Sprintf("%s %s", u.Kind, u.Name)
+	if u.Namespace != "" {
+		s = fmt.Sprintf("%s %s/%s", u.Kind, u.Namespace, u.Name)
+	}
+	if u.By != "" {
+		s += fmt.Sprintf(" (%s)", u.By)
+	}
+	return s
+}
+
+// protects reports whether the usage protects the resource in node. The
+// version is ignored since the usage may name another served version.
+func (u usage) protects(node *report.ResourceStatus) bool {
+	if u.OfKind != node.Kind || u.OfName != node.Name {
+		return false
+	}
+	if gv, err := schema.ParseGroupVersion(node.APIVersion); err != nil || gv.Group != u.OfGroup {
+		return false
+	}
+	// Namespaced Usages only protect resources in their own namespace
+	return u.Namespace == "" || u.Namespace == node.Namespace
+}
+
+// markBlockingUsages records the Usages that protect resources in the tree
+// from deletion. Protected resources below a deleting resource are marked, and
+// each deleting resource collects the Usages blocking it or its descendants.
+func (b *Builder) markBlockingUsages(ctx context.Context, root *report.ResourceStatus) {
+	usages := b.listUsages(ctx)
+	if len(usages) == 0 {
+		return
+	}
+	markUsages(root, usages, false)
+}
+
+func markUsages(node *report.ResourceStatus, usages []usage, deleting bool) []string {
+	deleting = deleting || node.Status == "Deleting"
+	if !deleting && !hasDeletion(node) {
+		return nil
+	}
+
+	var own []string
+	for _, u := range usages {
+		if u.protects(node) {
+			own = append(own, u.String())
+		}
+	}
+	if deleting {
+		node.BlockedBy = own
+	}
+
+	blocking := append([]string(nil), own...)
+	seen := make(map[string]bool)
+	for _, desc := range own {
+		seen[desc] = true
+	}
+	for i := range node.Children {
+		for _, desc := range markUsages(&node.Children[i], usages, deleting) {
+			if !seen[desc] {
+				seen[desc] = true
+				blocking = append(blocking, desc)
+			}
+		}
+	}
+	if node.Status == "Deleting" {
+		node.BlockedBy = blocking
+	}
+	return blocking
+}
+
+// hasDeletion reports whether node or any of its descendants is being deleted
+func hasDeletion(node *report.ResourceStatus) bool {
+	if node.Status == "Deleting" {
+		return true
+	}
+	for i := range node.Children {
+		if hasDeletion(&node.Children[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// listUsages lists the Usages in the cluster once per Builder. Usage types
+// that are not installed or cannot be listed are skipped.
+func (b *Builder) listUsages(ctx context.Context) []usage {
+	if b.usagesListed {
+		return b.usages
+	}
+	b.usagesListed = true
+
+	for _, versions := range usageGVRs {
+		for _, gvr := range versions {
+			listCtx, span := tracing.Start(ctx, "ListUsages", attribute.String("k8s.resource", gvr.String()))
+			list, err := b.List(listCtx, gvr, "", metav1.ListOptions{})
+			tracing.End(span, err)
+			if err != nil {
+				continue
+			}
+			for _, item := range list.Items {
+				b.usages = append(b.usages, newUsage(item))
+			}
+			break
+		}
+	}
+	return b.usages
+}
+
+// newUsage reads a Usage. A Usage that selects the protected resource with
+// spec.of.resourceSelector protects nothing until Crossplane resolves the
+// selector into spec.of.resourceRef, so only the resolved reference is used.
+func newUsage(obj unstructured.Unstructured) usage {
+	u := usage{
+		Kind:      obj.GetKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+	ofAPIVersion, _, _ := unstructured.NestedString(obj.Object, "spec", "of", "apiVersion")
+	if gv, err := schema.ParseGroupVersion(ofAPIVersion); err == nil {
+		u.OfGroup = gv.Group
+	}
+	u.OfKind, _, _ = unstructured.NestedString(obj.Object, "spec", "of", "kind")
+	u.OfName, _, _ = unstructured.NestedString(obj.Object, "spec", "of", "resourceRef", "name")
+
+	byKind, _, _ := unstructured.NestedString(obj.Object, "spec", "by", "kind")
+	byName, _, _ := unstructured.NestedString(obj.Object, "spec", "by", "resourceRef", "name")
+	if byKind != "" && byName != "" {
+		u.By = fmt.Sprintf("used by %s/%s", byKind, byName)
+	} else if reason, _, _ := unstructured.NestedString(obj.Object, "spec", "reason"); reason != "" {
+		u.By = reason
+	}
+	return u
+}
@@ -0,0 +1,92 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/vinishsoman/crossplane-diagnose/pkg/report"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func usageObject(namespace string, of map[string]interface{}) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "protection.crossplane.io/v1beta1",
+		"kind":       "Usage",
+		"spec":       map[string]interface{}{"of": of},
+	}}
+	obj.SetName("protect")
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+func TestUsageProtects(t *testing.T) {
+	vpc := map[string]interface{}{
+		"apiVersion":  "ec2.aws.upbound.io/v1beta1",
+		"kind":        "VPC",
+		"resourceRef": map[string]interface{}{"name": "prod"},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		of        map[string]interface{}
+		node      report.ResourceStatus
+		want      bool
+	}{
+		{
+			name: "same group, kind and name",
+			of:   vpc,
+			node: report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", Name: "prod"},
+			want: true,
+		},
+		{
+			name: "other served version",
+			of:   vpc,
+			node: report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta2", Kind: "VPC", Name: "prod"},
+			want: true,
+		},
+		{
+			name: "same kind in another group",
+			of:   vpc,
+			node: report.ResourceStatus{APIVersion: "ec2.aws.crossplane.io/v1beta1", Kind: "VPC", Name: "prod"},
+			want: false,
+		},
+		{
+			name: "other name",
+			of:   vpc,
+			node: report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", Name: "dev"},
+			want: false,
+		},
+		{
+			name:      "namespaced usage in the resource's namespace",
+			namespace: "payments",
+			of:        vpc,
+			node:      report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", Namespace: "payments", Name: "prod"},
+			want:      true,
+		},
+		{
+			name:      "namespaced usage in another namespace",
+			namespace: "orders",
+			of:        vpc,
+			node:      report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", Namespace: "payments", Name: "prod"},
+			want:      false,
+		},
+		{
+			name: "unresolved resource selector",
+			of: map[string]interface{}{
+				"apiVersion":       "ec2.aws.upbound.io/v1beta1",
+				"kind":             "VPC",
+				"resourceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"env": "prod"}},
+			},
+			node: report.ResourceStatus{APIVersion: "ec2.aws.upbound.io/v1beta1", Kind: "VPC", Name: "prod"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newUsage(usageObject(tt.namespace, tt.of))
+			if got := u.protects(&tt.node); got != tt.want {
+				t.Errorf("protects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}